@@ -0,0 +1,180 @@
+/*
+Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thetherington/IndexCreator/internal/app"
+)
+
+var (
+	applyYes bool
+)
+
+// outcome of a scenario entry
+const (
+	entryComplete = "complete"
+	entryFailed   = "failed"
+	entryInvalid  = "invalid"
+	entryPlanned  = "planned"
+)
+
+// entryResult is the outcome of a scenario entry and how many of its indices failed
+type entryResult struct {
+	status  string
+	indices int
+	failed  int
+}
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Subcommand used to build a demo environment from a scenario file",
+	Long: `This subcommand runs every entry of a scenario file (YAML) the way create or create import would,
+one entry after the other, and prints the outcome of each entry at the end
+
+Scenario file:
+  target:                       # optional, flags given on the command line win
+    urls: [http://localhost:9200]
+    user: elastic
+    password: changeme
+  defaults:                     # optional, any entry setting
+    last: 7d
+    on_conflict: skip
+  entries:
+    - name: syslog
+      reference: references/log-syslog-informational-2023.03.15.tar.gz
+      weekdays: mon-fri
+      transform:
+        timestamp_shift: duration
+        jitter: 10m
+        scale: 2
+    - name: metrics
+      references: [references/metrics-2023.03.tar.gz]
+      start: 2023-01-01
+      end: 2023-06-30
+      import: false             # only generate the import files
+
+Entry settings: name, reference, references, start, end, last, month, weekdays, granularity, max_days,
+import, app, backend, on_conflict, report and transform (timestamp_fields, timestamp_shift, jitter, scale, ids).
+They take the same values as the create import flags of the same name. References, report and the target ca, cert
+and key are relative to the scenario file
+
+Example Usage:
+  ./IndexCreator apply demo.yaml
+  ./IndexCreator apply --dry-run demo.yaml`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var app app.Config
+
+		if !app.ValidProgressArgs() {
+			os.Exit(1)
+		}
+
+		scenario, ok := app.ValidScenarioArgs(args)
+		if !ok {
+			os.Exit(1)
+		}
+
+		scenario.Target.Apply(cmd.Flags().Changed)
+
+		results := make([]entryResult, len(scenario.Entries))
+		failed := false
+
+		// headings and the summary are text, json progress output only has the status lines
+		out := app.Text()
+
+		for i := range scenario.Entries {
+			e := &scenario.Entries[i]
+
+			fmt.Fprintf(out, "[%d/%d] %s\n", i+1, len(scenario.Entries), e.Name)
+
+			results[i] = applyEntry(cmd, e)
+			failed = failed || (results[i].status != entryComplete && results[i].status != entryPlanned)
+
+			fmt.Fprintln(out)
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENTRY\tINDICES\tFAILED\tSTATUS")
+
+		for i, e := range scenario.Entries {
+			r := results[i]
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", e.Name, r.indices, r.failed, r.status)
+		}
+
+		w.Flush()
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// applyEntry runs a single scenario entry like create or create import
+func applyEntry(cmd *cobra.Command, e *app.ScenarioEntry) entryResult {
+	var app app.Config
+
+	opts, err := e.TransformOptions()
+	if err != nil {
+		fmt.Printf("Transform settings are invalid: %v\n", err)
+		return entryResult{status: entryInvalid}
+	}
+
+	dates := e.Dates(applyYes)
+
+	if !app.ValidCreateArgs(&dates, &opts, e.References) {
+		return entryResult{status: entryInvalid}
+	}
+
+	if e.Importing() && !app.ValidBackendArgs(&e.App, &e.Backend, &e.OnConflict) {
+		return entryResult{status: entryInvalid}
+	}
+
+	result := entryResult{status: entryComplete}
+	for _, ref := range app.References {
+		result.indices += len(ref.IndexDates)
+	}
+
+	if app.DryRun() {
+		app.PlanCreate(e.Importing())
+		result.status = entryPlanned
+		return result
+	}
+
+	if !app.ValidReportArgs(&e.Report, fmt.Sprintf("%s %s", cmd.CommandPath(), e.Name)) {
+		result.status = entryInvalid
+		return result
+	}
+
+	err = app.RunCreate(e.Importing())
+
+	for _, s := range app.Spinners {
+		if s.IsError() {
+			result.failed++
+		}
+	}
+
+	if result.failed > 0 {
+		result.status = entryFailed
+	}
+
+	if err != nil {
+		fmt.Printf("Can't write report file: %v\n", err)
+		result.status = entryFailed
+	}
+
+	return result
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	// Here you will define your flags and configuration settings.
+	applyCmd.Flags().BoolVarP(&applyYes, "yes", "y", false, "Don't ask for confirmation when the range of an entry is larger than its max_days")
+}
@@ -0,0 +1,212 @@
+/*
+Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is prepended to the upper cased flag name to get the environment variable of a flag
+const envPrefix = "INDEXCREATOR_"
+
+var configFile string
+
+// loadSettings fills the flags not given on the command line from the environment or else from the
+// config file. Values aren't marked as changed, a changed flag is always one given on the command line
+func loadSettings(cmd *cobra.Command) error {
+	path, explicit := configFile, configFile != ""
+
+	if !explicit {
+		path = os.Getenv(envName("config"))
+		explicit = path != ""
+	}
+
+	if !explicit {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(dir, "indexcreator.yaml")
+	}
+
+	values, err := readConfigFile(path, cmd)
+	if err != nil && (explicit || !os.IsNotExist(err)) {
+		return err
+	}
+
+	var ferr error
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if ferr != nil || f.Changed || f.Name == "config" || f.Name == "help" || f.Name == "version" {
+			return
+		}
+
+		source := "config file"
+		value, ok := values[f.Name]
+
+		if env, set := os.LookupEnv(envName(f.Name)); set {
+			source, value, ok = envName(f.Name), env, true
+		}
+
+		if !ok {
+			return
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			ferr = fmt.Errorf("%s value for --%s is invalid: %v", source, f.Name, err)
+		}
+	})
+
+	return ferr
+}
+
+// usesSettings reports whether the command runs with the settings loaded, help and shell completion
+// have to keep working with a broken config file
+func usesSettings(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "help" || c.Name() == "completion" || strings.HasPrefix(c.Name(), "__complete") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// envName is the environment variable of a flag, es-url is INDEXCREATOR_ES_URL
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// readConfigFile returns the flag values the config file has for a command. Keys are flag names and
+// apply to every command with that flag, a section named after a subcommand holds values for that
+// subcommand only (and its own subcommands), overriding the ones above it:
+//
+//	es-url: [https://es-1:9200, https://es-2:9200]
+//	app: mnt-1
+//	import:
+//	  on-conflict: skip
+//	create:
+//	  import:
+//	    on-conflict: overwrite
+func readConfigFile(path string, cmd *cobra.Command) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("can't parse config file %s: %v", path, err)
+	}
+
+	if err := checkSection(doc, cmd.Root(), cmd.Root().Name()); err != nil {
+		return nil, fmt.Errorf("config file %s: %v", path, err)
+	}
+
+	// the sections from the root down to the command, deeper sections win
+	var chain []*cobra.Command
+	for c := cmd; c != nil; c = c.Parent() {
+		chain = append([]*cobra.Command{c}, chain...)
+	}
+
+	values := make(map[string]string)
+	section := doc
+
+	for i, c := range chain {
+		if i > 0 {
+			next, ok := section[c.Name()].(map[string]interface{})
+			if !ok {
+				break
+			}
+			section = next
+		}
+
+		for key, v := range section {
+			if _, isSection := v.(map[string]interface{}); !isSection && v != nil {
+				values[key] = configValue(v)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// checkSection rejects keys that are neither a flag of the command (or one of its subcommands) nor
+// a subcommand section, a typo would otherwise be silently ignored
+func checkSection(section map[string]interface{}, cmd *cobra.Command, path string) error {
+	keys := make([]string, 0, len(section))
+	for key := range section {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if sub, ok := section[key].(map[string]interface{}); ok {
+			c := subcommand(cmd, key)
+			if c == nil {
+				return fmt.Errorf("%s has no subcommand %q", path, key)
+			}
+
+			if err := checkSection(sub, c, path+" "+key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !hasFlag(cmd, key) {
+			return fmt.Errorf("%s has no --%s flag", path, key)
+		}
+	}
+
+	return nil
+}
+
+func subcommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, c := range cmd.Commands() {
+		if c.Name() == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// hasFlag reports whether the command, one of its parents or one of its subcommands has the flag
+func hasFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.InheritedFlags().Lookup(name) != nil {
+		return true
+	}
+
+	for _, c := range cmd.Commands() {
+		if hasFlag(c, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// configValue formats a YAML value the way it would be given on the command line, lists are comma separated
+func configValue(v interface{}) string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return fmt.Sprint(v)
+	}
+
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = fmt.Sprint(item)
+	}
+
+	return strings.Join(items, ",")
+}
@@ -4,57 +4,91 @@ Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
 package create
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/thetherington/IndexCreator/internal/app"
+	"github.com/thetherington/IndexCreator/internal/transform"
 )
 
 var (
-	start string
-	end   string
+	dates         app.DateRangeArgs
+	report        string
+	transformOpts transform.Options
 )
 
 // createCmd represents the create command
 var CreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Subcommand used to generate inSITE import files (tar.gz)",
-	Long: `This subcommand is used to auto generate inSITE index import tar.gz files from a supplied start and end range
+	Long: `This subcommand is used to auto generate inSITE index import tar.gz files from a supplied start and end range.
+Several references (files, directories of references or quoted globs) can be given, every index is created for the whole range
 	
 Example Usage:
-  ./IndexCreator create --start 2023-03-20 --end 2023-03-25 log-syslog-informational-2023.03.15.tar.gz`,
-	Args: cobra.ExactArgs(1),
+  ./IndexCreator create --start 2023-03-20 --end 2023-03-25 log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator create --start -30d --end today log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator create --last 2w --weekdays mon-fri log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator create --month 2023-03 log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator create --start 2023-01-01 --end 2023-06-30 metrics-monthly-2023.03.tar.gz
+  ./IndexCreator create --last 7d references/
+  ./IndexCreator create --last 7d "references/log-*.tar.gz"`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		var app app.Config
 
-		if !app.ValidCreateArgs(&start, &end, args) {
+		if !app.ValidProgressArgs() {
 			os.Exit(1)
 		}
 
-		// Create spin group
-		sm := app.CreateSpinGroups()
-
-		sm.Start()
+		if !app.ValidCreateArgs(&dates, &transformOpts, args) {
+			os.Exit(1)
+		}
 
-		// iterate through each date and generate the import in a go routine
-		for x, d := range app.IndexDates {
-			app.Wg.Add(1)
+		if app.DryRun() {
+			app.PlanCreate(false)
+			return
+		}
 
-			go app.GenerateIndex(d, app.Spinners[x], true)
+		if !app.ValidReportArgs(&report, cmd.CommandPath()) {
+			os.Exit(1)
 		}
 
-		// wait for all to complete
-		app.Wg.Wait()
+		if err := app.RunCreate(false); err != nil {
+			fmt.Printf("Can't write report file: %v\n", err)
+			os.Exit(1)
+		}
 
-		sm.Stop()
+		if app.Failed() {
+			os.Exit(1)
+		}
 	},
 }
 
 func init() {
 	// Here you will define your flags and configuration settings.
-	CreateCmd.Flags().StringVarP(&start, "start", "s", "", "Start Date Format (YYYY-MM-DD)")
-	CreateCmd.Flags().StringVarP(&end, "end", "e", "", "End Date Format (YYYY-MM-DD)")
+	addDateFlags(CreateCmd, &dates)
+	addTransformFlags(CreateCmd, &transformOpts)
+	CreateCmd.Flags().StringVar(&report, "report", "", "Write a JSON summary of the run to this file")
+}
+
+// addDateFlags registers the date range flags shared by create and create import
+func addDateFlags(cmd *cobra.Command, dates *app.DateRangeArgs) {
+	cmd.Flags().StringVarP(&dates.Start, "start", "s", "", "Start date: YYYY-MM-DD, today, yesterday or relative to today (-30d, -2w, -1m, -1y)")
+	cmd.Flags().StringVarP(&dates.End, "end", "e", "", "End date, same formats as --start (default today)")
+	cmd.Flags().StringVar(&dates.Last, "last", "", "Span ending on --end (default today) instead of --start: 30d, 2w, 3m or 1y")
+	cmd.Flags().StringVar(&dates.Month, "month", "", "Every day of a calendar month (YYYY-MM) instead of --start")
+	cmd.Flags().StringVar(&dates.Weekdays, "weekdays", "", "Only these days of the week, such as mon-fri or mon,wed,fri (default every day)")
+	cmd.Flags().StringVar(&dates.Granularity, "granularity", "", "Index period: hourly, daily, weekly or monthly (default detected from the archive name, with weekly a YYYY.MM.DD reference is taken as weekly too)")
+	cmd.Flags().IntVar(&dates.MaxDays, "max-days", 366, "Ask for confirmation before creating more dates than this (0 for no limit)")
+	cmd.Flags().BoolVarP(&dates.Yes, "yes", "y", false, "Don't ask for confirmation when the range is larger than --max-days")
+}
 
-	CreateCmd.MarkFlagRequired("start")
-	CreateCmd.MarkFlagRequired("end")
+// addTransformFlags registers the document transform flags shared by create and create import
+func addTransformFlags(cmd *cobra.Command, opts *transform.Options) {
+	cmd.Flags().StringSliceVar(&opts.TimestampFields, "timestamp-field", []string{transform.DefaultTimestampField}, "Document timestamp field(s) to move to the new date")
+	cmd.Flags().StringVar(&opts.Shift, "timestamp-shift", transform.ShiftDate, "How timestamps are moved: date (swap the date only) or duration (shift by the time between dates, keeping time of day and offset)")
+	cmd.Flags().DurationVar(&opts.Jitter, "jitter", 0, "Random per document offset of up to +/- this duration (requires --timestamp-shift=duration)")
+	cmd.Flags().Float64Var(&opts.Scale, "scale", 1, "Document volume per day relative to the reference (0.25 samples a quarter, 3 writes three copies)")
+	cmd.Flags().StringVar(&opts.IDs, "ids", transform.IDKeep, "Document _id strategy: keep, uuid (random) or hash (of the new date and original _id)")
 }
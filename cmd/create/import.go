@@ -4,16 +4,22 @@ Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
 package create
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/thetherington/IndexCreator/internal/app"
+	"github.com/thetherington/IndexCreator/internal/transform"
 )
 
 var (
-	importStart string
-	importEnd   string
-	mntApp      string
+	importDates  app.DateRangeArgs
+	importReport string
+	mntApp       string
+	backend      string
+	onConflict   string
+
+	importTransformOpts transform.Options
 )
 
 // importCmd represents the import command
@@ -23,34 +29,42 @@ var importCmd = &cobra.Command{
 	Long: `This subcommand is used to auto import inSITE index import files after the auto creation has completed
 	
 Example Usage:
-  ./IndexCreator create import --start 2023-03-20 --end 2023-03-25 log-syslog-informational-2023.03.15.tar.gz`,
-	Args: cobra.ExactArgs(1),
+  ./IndexCreator create import --start 2023-03-20 --end 2023-03-25 log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator create import --last 7d log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator create import --last 7d references/`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		var app app.Config
 
-		if !app.ValidCreateArgs(&importStart, &importEnd, args) {
+		if !app.ValidProgressArgs() {
 			os.Exit(1)
 		}
 
-		if !app.ValidImportArgs(&mntApp, args) {
+		if !app.ValidCreateArgs(&importDates, &importTransformOpts, args) {
 			os.Exit(1)
 		}
 
-		// Create spin group
-		sm := app.CreateSpinGroups()
-
-		sm.Start()
+		if !app.ValidBackendArgs(&mntApp, &backend, &onConflict) {
+			os.Exit(1)
+		}
 
-		for x, d := range app.IndexDates {
-			app.Wg.Add(1)
+		if app.DryRun() {
+			app.PlanCreate(true)
+			return
+		}
 
-			go app.CreateImportIndex(d, app.Spinners[x])
+		if !app.ValidReportArgs(&importReport, cmd.CommandPath()) {
+			os.Exit(1)
 		}
 
-		// wait for all to complete
-		app.Wg.Wait()
+		if err := app.RunCreate(true); err != nil {
+			fmt.Printf("Can't write report file: %v\n", err)
+			os.Exit(1)
+		}
 
-		sm.Stop()
+		if app.Failed() {
+			os.Exit(1)
+		}
 	},
 }
 
@@ -58,10 +72,10 @@ func init() {
 	CreateCmd.AddCommand(importCmd)
 
 	// Here you will define your flags and configuration settings.
-	importCmd.Flags().StringVarP(&importStart, "start", "s", "", "Start Date Format (YYYY-MM-DD)")
-	importCmd.Flags().StringVarP(&importEnd, "end", "e", "", "End Date Format (YYYY-MM-DD)")
+	addDateFlags(importCmd, &importDates)
+	addTransformFlags(importCmd, &importTransformOpts)
 	importCmd.Flags().StringVarP(&mntApp, "app", "a", "mnt-1", "inSITE Elasticsearch Maintenance Program")
-
-	importCmd.MarkFlagRequired("start")
-	importCmd.MarkFlagRequired("end")
+	importCmd.Flags().StringVarP(&backend, "backend", "b", app.BackendNative, "Import backend (native or elasticdump)")
+	importCmd.Flags().StringVar(&importReport, "report", "", "Write a JSON summary of the run to this file")
+	importCmd.Flags().StringVar(&onConflict, "on-conflict", app.ConflictFail, "When the index already exists: skip it, overwrite (delete and recreate) it, append the data to it or fail")
 }
@@ -0,0 +1,74 @@
+/*
+Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thetherington/IndexCreator/internal/app"
+)
+
+var (
+	outputDir string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Subcommand used to export inSITE import files (tar.gz) from Elasticsearch",
+	Long: `This subcommand is used to export each index matching an index pattern into an inSITE index import tar.gz
+	
+Example Usage:
+  ./IndexCreator export log-syslog-informational-2023.03.15
+  ./IndexCreator export --output exports "log-syslog-*-2023.03.*"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var app app.Config
+
+		if !app.ValidProgressArgs() {
+			os.Exit(1)
+		}
+
+		if !app.ValidExportArgs(&outputDir, args) {
+			os.Exit(1)
+		}
+
+		if app.DryRun() {
+			app.PlanExport()
+			return
+		}
+
+		sm := app.CreateSpinGroupsExport()
+
+		sm.Start()
+
+		pool := app.NewPool(len(app.ExportIndices))
+
+		for x, index := range app.ExportIndices {
+			app.Wg.Add(1)
+
+			i, s := index, app.Spinners[x]
+			pool.Queue(func() { app.ExportIndex(i, s) })
+		}
+
+		pool.Close()
+
+		// wait for all to complete
+		app.Wg.Wait()
+
+		sm.Stop()
+
+		if app.Failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	// Here you will define your flags and configuration settings.
+	exportCmd.Flags().StringVarP(&outputDir, "output", "o", ".", "Directory to write the import files to")
+}
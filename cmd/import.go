@@ -4,6 +4,7 @@ Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -11,7 +12,12 @@ import (
 )
 
 var (
-	mntApp string
+	mntApp     string
+	backend    string
+	onConflict string
+	resume     bool
+	stateFile  string
+	report     string
 )
 
 // importCmd represents the import command
@@ -22,12 +28,31 @@ var importCmd = &cobra.Command{
 	
 Example Usage:
   ./IndexCreator import log-syslog-informational-2023.03.15.tar.gz
-  ./IndexCreator import log-syslog-informational-directory`,
+  ./IndexCreator import log-syslog-informational-directory
+  ./IndexCreator import --resume log-syslog-informational-directory
+  ./IndexCreator import --on-conflict=overwrite log-syslog-informational-directory`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		var app app.Config
 
-		if !app.ValidImportArgs(&mntApp, args) {
+		if !app.ValidProgressArgs() {
+			os.Exit(1)
+		}
+
+		if !app.ValidImportArgs(&mntApp, &backend, &onConflict, args) {
+			os.Exit(1)
+		}
+
+		if !app.ValidResumeArgs(&stateFile, &resume) {
+			os.Exit(1)
+		}
+
+		if app.DryRun() {
+			app.PlanImport()
+			return
+		}
+
+		if !app.ValidReportArgs(&report, cmd.CommandPath()) {
 			os.Exit(1)
 		}
 
@@ -35,16 +60,33 @@ Example Usage:
 
 		sm.Start()
 
+		pool := app.NewPool(len(app.ImportFiles))
+
 		for x, d := range app.ImportFiles {
 			app.Wg.Add(1)
 
-			go app.ImportIndex(d, app.Spinners[x])
+			f, s := d, app.Spinners[x]
+			pool.Queue(func() { app.ImportIndex(f, s) })
 		}
 
+		pool.Close()
+
 		// wait for all to complete
 		app.Wg.Wait()
 
 		sm.Stop()
+
+		if err := app.Report.Write(); err != nil {
+			fmt.Printf("Can't write report file: %v\n", err)
+			os.Exit(1)
+		}
+
+		if app.Failed() {
+			os.Exit(1)
+		}
+
+		// everything was imported, nothing left to resume
+		app.State.Remove()
 	},
 }
 
@@ -53,4 +95,9 @@ func init() {
 
 	// Here you will define your flags and configuration settings.
 	importCmd.Flags().StringVarP(&mntApp, "app", "a", "mnt-1", "inSITE Elasticsearch Maintenance Program")
+	importCmd.Flags().StringVarP(&backend, "backend", "b", app.BackendNative, "Import backend (native or elasticdump)")
+	importCmd.Flags().StringVar(&onConflict, "on-conflict", app.ConflictFail, "When the index already exists: skip it, overwrite (delete and recreate) it, append the data to it or fail")
+	importCmd.Flags().BoolVarP(&resume, "resume", "r", false, "Resume an interrupted import, skipping what the state file has as done")
+	importCmd.Flags().StringVar(&stateFile, "state-file", "IndexCreator-import.state", "File tracking the progress of the import")
+	importCmd.Flags().StringVar(&report, "report", "", "Write a JSON summary of the run to this file")
 }
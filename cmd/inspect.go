@@ -0,0 +1,53 @@
+/*
+Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thetherington/IndexCreator/internal/app"
+	"github.com/thetherington/IndexCreator/internal/transform"
+)
+
+var (
+	inspectTimestampField string
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Subcommand used to summarise an inSITE import file (tar.gz)",
+	Long: `This subcommand is used to summarise an inSITE index import tar.gz without extracting it.
+It prints the index name and date of the archive name, the shard and replica settings, the mapped fields and
+their types, the number of documents, the first and last timestamp and the number of documents per hour
+
+Example Usage:
+  ./IndexCreator inspect log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator inspect --timestamp-field event.created metrics-2023.03.tar.gz`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var app app.Config
+
+		if !app.ValidInspectArgs(args) {
+			os.Exit(1)
+		}
+
+		sum, err := app.InspectArchive(inspectTimestampField)
+		if err != nil {
+			fmt.Printf("Can't read archive: %v\n", err)
+			os.Exit(1)
+		}
+
+		app.PrintInspect(sum, inspectTimestampField)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	// Here you will define your flags and configuration settings.
+	inspectCmd.Flags().StringVar(&inspectTimestampField, "timestamp-field", transform.DefaultTimestampField, "Document field the timestamps and hourly counts are read from")
+}
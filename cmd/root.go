@@ -4,10 +4,13 @@ Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/thetherington/IndexCreator/cmd/create"
+	"github.com/thetherington/IndexCreator/internal/app"
+	"github.com/thetherington/IndexCreator/internal/elastic"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -19,7 +22,33 @@ var rootCmd = &cobra.Command{
 This application can perform the following:
   a) Auto generate index import files based on a date range.
   b) Import an inSITE index import file or a directory of import files.
-  c) Auto generate import files from an inSITE index export and then auto import into Elasticsearch.`,
+  c) Auto generate import files from an inSITE index export and then auto import into Elasticsearch.
+  d) Export inSITE import files from the indexes of a live Elasticsearch cluster.
+  e) Build a whole demo environment from a scenario file listing what to generate and import.
+  f) Verify inSITE import files are complete and consistent before passing them on.
+  g) Summarise an inSITE import file (settings, fields, documents and timestamps) without extracting it.
+
+Any flag can also be set with an environment variable named after it (--es-url is INDEXCREATOR_ES_URL) or in
+the config file (--config, ~/.config/indexcreator.yaml by default), the command line wins over the environment
+and the environment over the config file. Config file keys are flag names, a section named after a subcommand
+only applies to that subcommand:
+
+  es-url: [https://es-1:9200, https://es-2:9200]
+  es-user: elastic
+  app: mnt-1
+  insite-root: /opt/evertz/insite/parasite/applications
+  import:
+    on-conflict: skip`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if !usesSettings(cmd) {
+			return
+		}
+
+		if err := loadSettings(cmd); err != nil {
+			fmt.Printf("Can't load settings: %v\n", err)
+			os.Exit(1)
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -39,6 +68,22 @@ func Execute() {
 func init() {
 	// Here you will define your flags and configuration settings.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file with flag defaults (env INDEXCREATOR_CONFIG, default ~/.config/indexcreator.yaml)")
+	rootCmd.PersistentFlags().StringSliceVar(&app.Global.ElasticURLs, "es-url", []string{elastic.DefaultURL}, "Elasticsearch URL, repeat or comma separate for multiple hosts")
+	rootCmd.PersistentFlags().StringVar(&app.Global.ElasticAuth.Username, "es-user", "", "Elasticsearch basic auth username")
+	rootCmd.PersistentFlags().StringVar(&app.Global.ElasticAuth.Password, "es-password", "", "Elasticsearch basic auth password")
+	rootCmd.PersistentFlags().StringVar(&app.Global.ElasticAuth.APIKey, "es-api-key", "", "Elasticsearch API key, base64 encoded id:key (native backend only)")
+	rootCmd.PersistentFlags().StringVar(&app.Global.ElasticAuth.CACert, "es-ca", "", "CA bundle (PEM) used to verify the Elasticsearch certificate")
+	rootCmd.PersistentFlags().StringVar(&app.Global.ElasticAuth.ClientCert, "es-cert", "", "Client certificate (PEM) for Elasticsearch TLS authentication")
+	rootCmd.PersistentFlags().StringVar(&app.Global.ElasticAuth.ClientKey, "es-key", "", "Client certificate key (PEM) for Elasticsearch TLS authentication")
+	rootCmd.PersistentFlags().BoolVar(&app.Global.ElasticAuth.Insecure, "es-insecure", false, "Skip verification of the Elasticsearch TLS certificate")
+	rootCmd.PersistentFlags().IntVarP(&app.Global.Parallel, "parallel", "p", 4, "Maximum number of dates or files processed at once")
+	rootCmd.PersistentFlags().StringVar(&app.Global.Progress, "progress", app.ProgressAuto, "Progress output: spinner, plain (log lines), json (JSON lines) or none, auto uses spinner on a terminal and plain otherwise")
+	rootCmd.PersistentFlags().BoolVar(&app.Global.DryRun, "dry-run", false, "Print what would be generated, imported or exported without doing it")
+	rootCmd.PersistentFlags().StringVar(&app.Global.InsiteRoot, "insite-root", app.DefaultInsiteRoot, "Directory of the inSITE parasite applications, where --app is looked up")
+	rootCmd.PersistentFlags().StringVar(&app.Global.Node, "node", "", "node executable for the elasticdump backend (default the one of --app, then PATH)")
+	rootCmd.PersistentFlags().StringVar(&app.Global.ElasticDump, "elasticdump", "", "elasticdump executable for the elasticdump backend (default the one of --app, then PATH)")
 	rootCmd.AddCommand(create.CreateCmd)
 
 	rootCmd.Version = "0.1"
@@ -0,0 +1,68 @@
+/*
+Copyright © 2023 Tom Hetherington <thomas@hetheringtons.org>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thetherington/IndexCreator/internal/app"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Subcommand used to check inSITE import files (tar.gz) are complete",
+	Long: `This subcommand is used to check an inSITE index import tar.gz, or every one in a directory, can be imported.
+Each archive is read to the end and must hold the settings, mapping and data files named after the index and date
+of the archive, with every line of the data file an elasticdump record
+	
+Example Usage:
+  ./IndexCreator verify log-syslog-informational-2023.03.15.tar.gz
+  ./IndexCreator verify log-syslog-informational-directory`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var app app.Config
+
+		if !app.ValidProgressArgs() {
+			os.Exit(1)
+		}
+
+		if !app.ValidVerifyArgs(args) {
+			os.Exit(1)
+		}
+
+		sm := app.CreateSpinGroupsVerify()
+
+		sm.Start()
+
+		pool := app.NewPool(len(app.VerifyFiles))
+
+		for x := range app.VerifyFiles {
+			app.Wg.Add(1)
+
+			i, s := x, app.Spinners[x]
+			pool.Queue(func() { app.VerifyArchive(i, s) })
+		}
+
+		pool.Close()
+
+		// wait for all to complete
+		app.Wg.Wait()
+
+		sm.Stop()
+
+		fmt.Println()
+		app.PrintVerify()
+
+		if app.Failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
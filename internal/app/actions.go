@@ -1,65 +1,135 @@
 package app
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/chelnak/ysmrr"
 	"github.com/thetherington/IndexCreator/internal/helpers"
+	"github.com/thetherington/IndexCreator/internal/transform"
 )
 
-func (config *Config) CreateImportIndex(dt time.Time, s *ysmrr.Spinner) {
+func (config *Config) CreateImportIndex(ref *Reference, dt time.Time, s Progress) {
 	defer config.Wg.Done()
 
-	config.Wg.Add(1)
+	date := ref.label(dt)
+	archive := ref.archivePath(dt)
+	index := ref.indexName(dt)
 
-	path, date := config.GenerateIndex(dt, s)
+	var err error
 
-	for _, arg := range []string{"settings", "mapping", "data"} {
+	config.Report.Start(archive, ReportItem{Date: ref.Granularity.Display(dt), Index: index})
+	defer func() {
+		config.Report.Finish(archive, err)
+		ref.finished(err)
+	}()
 
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", date, fmt.Sprintf("Importing %s...", arg)))
+	// decide on an existing index before spending time generating for it
+	phases, decision, replace, err := config.resolveConflict(index, archive, s, date)
+	if err != nil {
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", date, err.Error()))
+		s.Error()
+		return
+	}
 
-		args := []string{
-			config.ElasticDumpPath,
-			fmt.Sprintf("--input=%s", filepath.Join(path, fmt.Sprintf("%s-%s-%s.json", config.Index, date, arg))),
-			fmt.Sprintf("--output=http://localhost:9200/%s-%s", config.Index, date),
-			fmt.Sprintf("--type=%s", arg),
-			"--concurrencyInterval=500",
-			"--limit=1000",
-			"--intervalCap=10",
-		}
+	if len(phases) == 0 {
+		config.Report.Skip(archive, decision)
+		s.UpdateMessage(fmt.Sprintf("%s -- Complete (%s)", date, decision))
+		s.Complete()
+		return
+	}
 
-		// fmt.Println(args)
-		helpers.ElasticDumpRun(config.NodePath, args, s, date)
+	config.Report.Note(archive, decision)
 
+	err = config.generate(ref, []time.Time{dt}, []Progress{s})[0]
+	if err != nil {
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", date, err.Error()))
+		s.Error()
+		return
 	}
 
-	// Delete the work dir
-	s.UpdateMessage(fmt.Sprintf("%s -- Cleaning...", date))
+	err = config.importArchive(archive, phases, replace, s, date)
+	if err != nil {
+		os.Remove(archive)
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", date, err.Error()))
+		s.Error()
+		return
+	}
 
-	err := os.RemoveAll(path)
+	// the generated archive is only an intermediate file here
+	err = os.Remove(archive)
 	if err != nil {
 		s.UpdateMessage(fmt.Sprintf("%s -- %s", date, err.Error()))
 		s.Error()
 		return
 	}
 
-	s.UpdateMessage(fmt.Sprintf("%s -- Complete", date))
+	s.UpdateMessage(fmt.Sprintf("%s -- %s", date, complete(decision)))
 	s.Complete()
 }
 
-func (config *Config) ImportIndex(f string, s *ysmrr.Spinner) {
+func (config *Config) ImportIndex(f string, s Progress) {
 	defer config.Wg.Done()
 
-	r, err := os.Open(fmt.Sprintf("./%s", f))
+	index := filepath.Base(strings.TrimSuffix(f, ".tar.gz"))
+
+	var err error
+
+	config.Report.Start(f, ReportItem{Archive: f, Index: index})
+	defer func() { config.Report.Finish(f, err) }()
+
+	if info, serr := os.Stat(f); serr == nil {
+		config.Report.Bytes(f, info.Size())
+	}
+
+	// data is the last phase, once it's done there is nothing left to resume
+	if config.State.Done(f, "data") {
+		config.Report.Skip(f, "already imported")
+		s.UpdateMessage(fmt.Sprintf("%s -- Already imported", f))
+		s.Complete()
+		return
+	}
+
+	phases, decision, replace, err := config.resolveConflict(index, f, s, f)
+	if err == nil && len(phases) == 0 {
+		config.Report.Skip(f, decision)
+	}
+	if err == nil && len(phases) > 0 {
+		config.Report.Note(f, decision)
+		err = config.importArchive(f, phases, replace, s, f)
+	}
 	if err != nil {
 		s.UpdateMessage(fmt.Sprintf("%s -- %s", f, err.Error()))
 		s.Error()
 		return
 	}
+
+	s.UpdateMessage(fmt.Sprintf("%s -- %s", f, complete(decision)))
+	s.Complete()
+}
+
+// complete is the final spinner message, noting how an existing index was dealt with
+func complete(decision string) string {
+	if decision == "" {
+		return "Complete"
+	}
+
+	return fmt.Sprintf("Complete (%s)", decision)
+}
+
+// importArchive extracts an import file next to itself, imports the given phases (in order) skipping
+// the ones the import state has as done and then deletes the extracted files. With replace the existing
+// index is deleted between the extraction and the first phase
+func (config *Config) importArchive(f string, phases []string, replace bool, s Progress, label string) error {
+	r, err := os.Open(f)
+	if err != nil {
+		return err
+	}
 	defer r.Close()
 
 	path := strings.TrimSuffix(f, ".tar.gz")
@@ -67,143 +137,310 @@ func (config *Config) ImportIndex(f string, s *ysmrr.Spinner) {
 	// Make directory based on date and Untar the reference tar.gz file
 	err = os.MkdirAll(path, MODE)
 	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", f, err.Error()))
-		s.Error()
-		return
+		return err
 	}
 
+	s.UpdateMessage(fmt.Sprintf("%s -- Extracting...", label))
+
 	err = helpers.Untar(path, r)
-	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", f, err.Error()))
-		s.Error()
-		return
+
+	// scan through each file (in order) and import it
+	index := filepath.Base(path)
+
+	if err == nil && replace {
+		err = config.replaceIndex(index, s, label)
 	}
 
-	// scan through each file (in order) and run ElasticDump
-	path_slice := strings.Split(path, "/")
-	index := path_slice[len(path_slice)-1]
+	for _, arg := range phases {
+		if err != nil {
+			break
+		}
+
+		if config.State.Done(f, arg) {
+			continue
+		}
 
-	for _, arg := range []string{"settings", "mapping", "data"} {
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", label, fmt.Sprintf("Importing %s...", arg)))
 
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", f, fmt.Sprintf("Importing %s...", arg)))
+		config.Report.Phase(f, arg)
 
-		args := []string{
-			config.ElasticDumpPath,
-			fmt.Sprintf("--input=%s", filepath.Join(path, fmt.Sprintf("%s-%s.json", index, arg))),
-			fmt.Sprintf("--output=http://localhost:9200/%s", index),
-			fmt.Sprintf("--type=%s", arg),
-			"--concurrencyInterval=500",
-			"--limit=1000",
-			"--intervalCap=10",
+		err = config.importPhase(path, index, index, arg, f, s, label)
+		if err == nil {
+			err = config.State.Complete(f, arg)
 		}
+	}
 
-		helpers.ElasticDumpRun(config.NodePath, args, s, f)
+	// Delete the work dir, even when the import failed
+	s.UpdateMessage(fmt.Sprintf("%s -- Cleaning...", label))
 
+	if rerr := os.RemoveAll(path); err == nil {
+		err = rerr
 	}
 
-	// Delete the work dir
-	s.UpdateMessage(fmt.Sprintf("%s -- Cleaning...", f))
+	return err
+}
 
-	err = os.RemoveAll(path)
-	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", f, err.Error()))
-		s.Error()
-		return
+// RunCreate generates an import file for every date of the references, importing each one when importing
+// is set, behind a spinner per date and then writes the report. Failed dates show on their spinner, the
+// error returned is the one writing the report
+func (config *Config) RunCreate(importing bool) error {
+	sm := config.CreateSpinGroups()
+
+	sm.Start()
+
+	if importing {
+		pool := config.NewPool(len(config.Spinners))
+
+		for _, r := range config.References {
+			for x, d := range r.IndexDates {
+				config.Wg.Add(1)
+
+				ref, dt, s := r, d, r.Spinners[x]
+				pool.Queue(func() { config.CreateImportIndex(ref, dt, s) })
+			}
+		}
+
+		pool.Close()
+	} else {
+		// generate the imports in batches of --parallel dates, each from a single read of the reference archive
+		config.Wg.Add(1)
+
+		go config.GenerateIndexes()
 	}
 
-	s.UpdateMessage(fmt.Sprintf("%s -- Complete", f))
-	s.Complete()
+	// wait for all to complete
+	config.Wg.Wait()
+
+	sm.Stop()
+
+	return config.Report.Write()
 }
 
-func (config *Config) GenerateIndex(dt time.Time, s *ysmrr.Spinner, cleanup ...bool) (path string, new_date string) {
+// GenerateIndexes creates an import file for each date of every reference, one reference after the
+// other. The dates are processed in batches of --parallel dates, each batch from a single read of
+// the reference archive
+func (config *Config) GenerateIndexes() {
 	defer config.Wg.Done()
 
-	new_date = dt.Format("2006.01.02")
+	size := Global.Parallel
+	if size < 1 {
+		size = 1
+	}
 
-	r, err := os.Open(fmt.Sprintf("./%s", config.Filename))
+	for _, ref := range config.References {
+		dates, spinners := ref.IndexDates, ref.Spinners
+
+		for start := 0; start < len(dates); start += size {
+			end := start + size
+			if end > len(dates) {
+				end = len(dates)
+			}
+
+			for _, dt := range dates[start:end] {
+				config.Report.Start(ref.archivePath(dt), ReportItem{
+					Date:  ref.Granularity.Display(dt),
+					Index: ref.indexName(dt),
+				})
+			}
+
+			for i, err := range config.generate(ref, dates[start:end], spinners[start:end]) {
+				date := ref.label(dates[start+i])
+				s := spinners[start+i]
+
+				config.Report.Finish(ref.archivePath(dates[start+i]), err)
+
+				if err != nil {
+					s.UpdateMessage(fmt.Sprintf("%s -- %s", date, err.Error()))
+					s.Error()
+				} else {
+					s.UpdateMessage(fmt.Sprintf("%s -- Complete", date))
+					s.Complete()
+				}
+
+				ref.finished(err)
+			}
+		}
+	}
+}
+
+// generate streams the reference archive through the document rewriter into a new archive per
+// date and returns the outcome of each date. Archives that failed are removed
+func (config *Config) generate(ref *Reference, dates []time.Time, spinners []Progress) []error {
+	errs := make([]error, len(dates))
+
+	fail := func(err error) []error {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+		return errs
+	}
+
+	r, err := os.Open(ref.Filename)
 	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
-		s.Error()
-		return
+		return fail(err)
 	}
 	defer r.Close()
 
-	path = filepath.Join(config.Index, new_date)
+	err = os.MkdirAll(ref.Index, MODE)
+	if err != nil {
+		return fail(err)
+	}
+
+	targets := make([]*transform.Target, len(dates))
+	files := make([]*os.File, len(dates))
 
-	// Make directory based on date and Untar the reference tar.gz file
-	err = os.MkdirAll(path, MODE)
+	for i, dt := range dates {
+		date := ref.label(dt)
+		s := spinners[i]
+
+		files[i], err = os.Create(ref.archivePath(dt))
+		if err != nil {
+			targets[i] = &transform.Target{Err: err}
+			continue
+		}
+
+		targets[i] = &transform.Target{
+			Rewriter: &transform.Rewriter{
+				Options:  config.Transform,
+				OldIndex: fmt.Sprintf("%s-%s", ref.Index, ref.FileDate),
+				NewIndex: ref.indexName(dt),
+				OldDate:  ref.ReferenceDate,
+				NewDate:  dt,
+
+				OldGranularity: ref.ReferenceGranularity,
+				Granularity:    ref.Granularity,
+			},
+			Writer: files[i],
+			Progress: func(docs int) {
+				s.UpdateMessage(fmt.Sprintf("%s -- Generated %d documents", date, docs))
+			},
+		}
+
+		config.Report.Phase(ref.archivePath(dt), "generate")
+		s.UpdateMessage(fmt.Sprintf("%s -- Generating (%s with %s)...", date, targets[i].Rewriter.OldIndex, targets[i].Rewriter.NewIndex))
+	}
+
+	err = transform.Generate(r, ref.Index, targets...)
 	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
-		s.Error()
-		return
+		fail(err)
 	}
 
-	err = helpers.Untar(path, r)
+	for i, t := range targets {
+		if errs[i] == nil {
+			errs[i] = t.Err
+		}
+
+		if files[i] == nil {
+			continue
+		}
+
+		if cerr := files[i].Close(); errs[i] == nil {
+			errs[i] = cerr
+		}
+
+		if errs[i] != nil {
+			os.Remove(files[i].Name())
+			continue
+		}
+
+		config.Report.Documents(files[i].Name(), t.Docs())
+
+		if info, err := os.Stat(files[i].Name()); err == nil {
+			config.Report.Bytes(files[i].Name(), info.Size())
+		}
+	}
+
+	return errs
+}
+
+func (config *Config) ExportIndex(index string, s Progress) {
+	defer config.Wg.Done()
+
+	var settings, mapping bytes.Buffer
+
+	s.UpdateMessage(fmt.Sprintf("%s -- Exporting settings...", index))
+
+	err := config.Elastic.ExportSettings(index, &settings)
 	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", index, err.Error()))
 		s.Error()
 		return
 	}
 
-	// replace Index name to new Index name
-	old_index_pattern := fmt.Sprintf("%s-%s", config.Index, config.FileDate)
-	new_index_pattern := fmt.Sprintf("%s-%s", config.Index, new_date)
-
-	s.UpdateMessage(fmt.Sprintf("%s -- Replacing (%s with %s)...", new_date, old_index_pattern, new_index_pattern))
+	s.UpdateMessage(fmt.Sprintf("%s -- Exporting mapping...", index))
 
-	err = helpers.SedReplace(path, old_index_pattern, new_index_pattern)
+	err = config.Elastic.ExportMapping(index, &mapping)
 	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", index, err.Error()))
 		s.Error()
 		return
 	}
 
-	// replace @timestamp with new date value
-	old_date_pattern := strings.ReplaceAll(config.FileDate, ".", "-")
-	new_date_pattern := strings.ReplaceAll(new_date, ".", "-")
+	// the data is spooled to a temporary file since the tar header needs the size upfront
+	s.UpdateMessage(fmt.Sprintf("%s -- Exporting data...", index))
 
-	s.UpdateMessage(fmt.Sprintf("%s -- Replacing (%s with %s)...", new_date, old_date_pattern, new_date_pattern))
-
-	err = helpers.SedReplace(path, old_date_pattern, new_date_pattern)
+	data, err := os.CreateTemp(config.OutputDir, fmt.Sprintf(".%s-data-*.json", index))
 	if err != nil {
-		s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", index, err.Error()))
 		s.Error()
 		return
 	}
+	defer os.Remove(data.Name())
+	defer data.Close()
 
-	if len(cleanup) > 0 {
-
-		// Create new tar.gz file
-		s.UpdateMessage(fmt.Sprintf("%s -- Taring...", new_date))
+	bw := bufio.NewWriter(data)
 
-		w, err := os.Create(fmt.Sprintf("./%s/%s-%s.tar.gz", config.Index, config.Index, new_date))
-		if err != nil {
-			s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
-			s.Error()
-			return
-		}
+	docs, err := config.Elastic.ExportData(index, bw, func(docs int) {
+		s.UpdateMessage(fmt.Sprintf("%s -- Exported %d documents", index, docs))
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", index, err.Error()))
+		s.Error()
+		return
+	}
 
-		err = helpers.Tar(path, w)
-		if err != nil {
-			s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
-			s.Error()
-			return
-		}
+	size, err := data.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = data.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", index, err.Error()))
+		s.Error()
+		return
+	}
 
-		// Delete the work dir
-		s.UpdateMessage(fmt.Sprintf("%s -- Cleaning...", new_date))
+	// Create new tar.gz file
+	s.UpdateMessage(fmt.Sprintf("%s -- Taring...", index))
 
-		err = os.RemoveAll(path)
-		if err != nil {
-			s.UpdateMessage(fmt.Sprintf("%s -- %s", new_date, err.Error()))
-			s.Error()
-			return
-		}
+	w, err := os.Create(filepath.Join(config.OutputDir, fmt.Sprintf("%s.tar.gz", index)))
+	if err != nil {
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", index, err.Error()))
+		s.Error()
+		return
+	}
 
-		s.UpdateMessage(fmt.Sprintf("%s -- Complete", new_date))
-		s.Complete()
+	err = helpers.TarEntries(w,
+		helpers.TarEntry{Name: fmt.Sprintf("%s-settings.json", index), Size: int64(settings.Len()), Body: &settings},
+		helpers.TarEntry{Name: fmt.Sprintf("%s-mapping.json", index), Size: int64(mapping.Len()), Body: &mapping},
+		helpers.TarEntry{Name: fmt.Sprintf("%s-data.json", index), Size: size, Body: data},
+	)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
 
+	// a truncated archive would be picked up by a later import of the directory
+	if err != nil {
+		os.Remove(w.Name())
+		s.UpdateMessage(fmt.Sprintf("%s -- %s", index, err.Error()))
+		s.Error()
+		return
 	}
 
-	return
+	s.UpdateMessage(fmt.Sprintf("%s -- Complete (%d documents)", index, docs))
+	s.Complete()
 }
@@ -4,47 +4,99 @@ import (
 	"fmt"
 	"strings"
 	"sync"
-	"time"
 
-	"github.com/chelnak/ysmrr"
+	"github.com/thetherington/IndexCreator/internal/elastic"
+	"github.com/thetherington/IndexCreator/internal/helpers"
+	"github.com/thetherington/IndexCreator/internal/transform"
 )
 
 const MODE = 0755
 
 type Config struct {
-	Filename        string
-	Index           string
-	FileDate        string
+	References      []*Reference
 	MaintenanceApp  string
 	NodePath        string
 	ElasticDumpPath string
-	IndexDates      []time.Time
-	Spinners        []*ysmrr.Spinner
+	Tools           []Tool
+	Backend         string
+	OnConflict      string
+	Elastic         *elastic.Client
+	Transform       transform.Options
+	State           *State
+	Report          *Report
+	Spinners        []Progress
 	ImportFiles     []string
+	ExportIndices   []string
+	VerifyFiles     []string
+	Checks          []*helpers.ArchiveCheck
+	InspectFile     string
+	OutputDir       string
 	Wg              sync.WaitGroup
 }
 
-func (config *Config) CreateSpinGroups() ysmrr.SpinnerManager {
-	sm := ysmrr.NewSpinnerManager()
+// CreateSpinGroups adds a spinner per date, grouped under a summary line per reference when
+// there is more than one
+func (config *Config) CreateSpinGroups() Output {
+	sm := newOutput(Global.Progress)
 
-	for i := 0; i < len(config.IndexDates); i++ {
-		s := sm.AddSpinner(fmt.Sprintf("%s -- Extracting...", config.IndexDates[i].Format("2006.01.02")))
-		config.Spinners = append(config.Spinners, s)
+	for _, ref := range config.References {
+		if len(config.References) > 1 {
+			ref.group = sm.AddSpinner(fmt.Sprintf("%s -- Queued (%d %s indices)...", ref.Index, len(ref.IndexDates), ref.Granularity))
+		}
+
+		for _, dt := range ref.IndexDates {
+			s := sm.AddSpinner(fmt.Sprintf("%s -- Queued...", ref.label(dt)))
+			ref.Spinners = append(ref.Spinners, s)
+			config.Spinners = append(config.Spinners, s)
+		}
 	}
 
 	return sm
 }
 
-func (config *Config) CreateSpinGroupsImport() ysmrr.SpinnerManager {
-	sm := ysmrr.NewSpinnerManager()
+func (config *Config) CreateSpinGroupsImport() Output {
+	sm := newOutput(Global.Progress)
 
 	for i := 0; i < len(config.ImportFiles); i++ {
 
 		p := strings.Split(config.ImportFiles[i], "/")
-		s := sm.AddSpinner(fmt.Sprintf("%s -- Extracting...", p[len(p)-1]))
+		s := sm.AddSpinner(fmt.Sprintf("%s -- Queued...", p[len(p)-1]))
+
+		config.Spinners = append(config.Spinners, s)
+	}
+
+	return sm
+}
+
+func (config *Config) CreateSpinGroupsExport() Output {
+	sm := newOutput(Global.Progress)
+
+	for i := 0; i < len(config.ExportIndices); i++ {
+		s := sm.AddSpinner(fmt.Sprintf("%s -- Queued...", config.ExportIndices[i]))
+		config.Spinners = append(config.Spinners, s)
+	}
+
+	return sm
+}
 
+func (config *Config) CreateSpinGroupsVerify() Output {
+	sm := newOutput(Global.Progress)
+
+	for _, f := range config.VerifyFiles {
+		s := sm.AddSpinner(fmt.Sprintf("%s -- Queued...", f))
 		config.Spinners = append(config.Spinners, s)
 	}
 
 	return sm
 }
+
+// Failed reports whether any of the work items ended in error
+func (config *Config) Failed() bool {
+	for _, s := range config.Spinners {
+		if s.IsError() {
+			return true
+		}
+	}
+
+	return false
+}
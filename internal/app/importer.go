@@ -0,0 +1,249 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+)
+
+const (
+	BackendNative      = "native"
+	BackendElasticDump = "elasticdump"
+)
+
+// what to do when the index being imported already exists (--on-conflict)
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictAppend    = "append"
+	ConflictFail      = "fail"
+)
+
+var importPhases = []string{"settings", "mapping", "data"}
+
+// resolveConflict checks whether the index already exists and applies the conflict policy. It returns
+// the phases left to import (none when the import is skipped), the decision taken, if any, and whether
+// the existing index has to be replaced. The index isn't deleted here, replaceIndex does that once there
+// is something to replace it with. key identifies the import in the import state, an index created by
+// an earlier run of a resumed import is not a conflict
+func (config *Config) resolveConflict(index, key string, s Progress, label string) ([]string, string, bool, error) {
+	for _, phase := range importPhases {
+		if config.State.Done(key, phase) {
+			return importPhases, "", false, nil
+		}
+	}
+
+	if config.State.Offset(key) > 0 {
+		return importPhases, "", false, nil
+	}
+
+	exists, err := config.Elastic.Exists(index)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("can't check whether %s exists: %v", index, err)
+	}
+
+	if !exists {
+		return importPhases, "", false, nil
+	}
+
+	switch config.OnConflict {
+	case ConflictSkip:
+		return nil, "skipped, index exists", false, nil
+
+	case ConflictAppend:
+		s.UpdateMessage(fmt.Sprintf("%s -- Index exists, appending data...", label))
+
+		// the index already has its settings and mapping, record that so a resumed run doesn't try them
+		for _, phase := range []string{"settings", "mapping"} {
+			if err := config.State.Complete(key, phase); err != nil {
+				return nil, "", false, err
+			}
+		}
+
+		return []string{"data"}, "appended to existing index", false, nil
+
+	case ConflictOverwrite:
+		return importPhases, "existing index overwritten", true, nil
+	}
+
+	return nil, "", false, fmt.Errorf("index %s already exists", index)
+}
+
+// replaceIndex deletes the index an overwrite replaces, only called once the archive to import is
+// extracted so a failed generate or a corrupt archive leaves the existing index alone
+func (config *Config) replaceIndex(index string, s Progress, label string) error {
+	s.UpdateMessage(fmt.Sprintf("%s -- Index exists, deleting...", label))
+
+	if err := config.Elastic.Delete(index); err != nil {
+		return fmt.Errorf("can't delete %s: %v", index, err)
+	}
+
+	return nil
+}
+
+// importPhase imports one of the settings/mapping/data files found in path into the index
+// using the configured backend. file is the file name prefix, key identifies the import in the
+// import state (data resumes from the recorded offset) and label prefixes the spinner messages
+func (config *Config) importPhase(path, file, index, phase, key string, s Progress, label string) error {
+	input := filepath.Join(path, fmt.Sprintf("%s-%s.json", file, phase))
+	offset := config.State.Offset(key)
+
+	if config.Backend == BackendElasticDump {
+		authFile, err := config.elasticDumpAuthFile()
+		if err != nil {
+			return err
+		}
+
+		if authFile != "" {
+			defer os.Remove(authFile)
+		}
+
+		// documents written by every attempt, the report counts them for the data phase
+		docs := 0
+
+		if phase == "data" {
+			defer func() { config.Report.Documents(key, docs) }()
+		}
+
+		// elasticdump gets a single host, the next one in this import's own order is tried when it can't
+		// be reached, imports running in parallel don't move it on
+		hosts := config.Elastic.Rotation()
+
+		for attempt := 1; ; attempt++ {
+			attemptDocs, attemptOffset := docs, offset
+
+			output, extra, env := config.elasticDumpOutput(hosts[attempt-1], index)
+
+			args := []string{
+				config.ElasticDumpPath,
+				fmt.Sprintf("--input=%s", input),
+				fmt.Sprintf("--output=%s", output),
+				fmt.Sprintf("--type=%s", phase),
+				"--concurrencyInterval=500",
+				"--limit=1000",
+				"--intervalCap=10",
+			}
+
+			if phase == "data" && offset > 0 {
+				args = append(args, fmt.Sprintf("--offset=%d", offset))
+			}
+
+			if authFile != "" {
+				extra = append(extra, fmt.Sprintf("--httpAuthFile=%s", authFile))
+			}
+
+			// the documents sent are recorded as the offset a resumed import (or the next attempt) starts from
+			err = helpers.ElasticDumpRun(config.NodePath, append(args, extra...), env, s, label, func(sent, wrote int) error {
+				docs = attemptDocs + wrote
+
+				if phase != "data" {
+					return nil
+				}
+
+				offset = attemptOffset + sent
+
+				if err := config.State.SetOffset(key, offset); err != nil {
+					return fmt.Errorf("can't record the import offset: %v", err)
+				}
+
+				return nil
+			})
+
+			var dumpErr *helpers.ElasticDumpError
+			if !errors.As(err, &dumpErr) || !dumpErr.Unreachable() || attempt >= len(hosts) {
+				return err
+			}
+
+			if u, perr := url.Parse(output); perr == nil {
+				s.UpdateMessage(fmt.Sprintf("%s -- %s unreachable, trying the next host...", label, u.Host))
+			}
+		}
+	}
+
+	r, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	switch phase {
+	case "settings":
+		return config.Elastic.ImportSettings(index, r)
+
+	case "mapping":
+		return config.Elastic.ImportMapping(index, r)
+
+	default:
+		docs, err := config.Elastic.ImportData(index, r, offset, func(docs int) error {
+			s.UpdateMessage(fmt.Sprintf("%s -- Imported %d documents", label, docs))
+
+			if err := config.State.SetOffset(key, docs); err != nil {
+				return fmt.Errorf("can't record the import offset: %v", err)
+			}
+
+			return nil
+		})
+
+		config.Report.Documents(key, docs-offset)
+
+		return err
+	}
+}
+
+// elasticDumpOutput returns the elasticdump output URL for the index on host along with the extra arguments and
+// environment needed to reach a secured cluster. The credentials are not part of it, elasticdump's command
+// line can be read by any local user
+func (config *Config) elasticDumpOutput(host, index string) (output string, args []string, env []string) {
+	auth := config.Elastic.Auth()
+
+	// the path of --es-url is kept, the cluster may be behind a proxy under a prefix
+	u, _ := url.Parse(host)
+	u.Path = path.Join("/", u.Path, index)
+
+	if auth.CACert != "" {
+		args = append(args, fmt.Sprintf("--output-ca=%s", auth.CACert))
+	}
+
+	if auth.ClientCert != "" {
+		args = append(args, fmt.Sprintf("--output-cert=%s", auth.ClientCert), fmt.Sprintf("--output-key=%s", auth.ClientKey))
+	}
+
+	if auth.Insecure {
+		env = append(env, "NODE_TLS_REJECT_UNAUTHORIZED=0")
+	}
+
+	return u.String(), args, env
+}
+
+// elasticDumpAuthFile writes the username and password to a file only the current user can read, for
+// elasticdump's --httpAuthFile. It returns an empty name when there are no credentials, the caller
+// removes the file once elasticdump is done
+func (config *Config) elasticDumpAuthFile() (string, error) {
+	auth := config.Elastic.Auth()
+	if auth.Username == "" {
+		return "", nil
+	}
+
+	// CreateTemp creates the file with 0600 permissions
+	f, err := os.CreateTemp("", "indexcreator-auth-*")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = fmt.Fprintf(f, "user=%s\npassword=%s\n", auth.Username, auth.Password)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("can't write the elasticdump credentials: %v", err)
+	}
+
+	return f.Name(), nil
+}
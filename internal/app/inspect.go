@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+	"github.com/thetherington/IndexCreator/internal/transform"
+)
+
+// widest bar of the hourly histogram
+const histogramWidth = 50
+
+// InspectArchive reads the archive to inspect, field is the timestamp field of the documents
+func (config *Config) InspectArchive(field string) (*transform.Summary, error) {
+	f, err := os.Open(config.InspectFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return transform.Inspect(f, field)
+}
+
+// PrintInspect prints the summary of the archive, the index name and date are parsed from the
+// archive name the way create does
+func (config *Config) PrintInspect(sum *transform.Summary, field string) {
+	size := "unknown"
+	if info, err := os.Stat(config.InspectFile); err == nil {
+		size = helpers.HumanBytes(info.Size())
+	}
+
+	fmt.Printf("Archive:     %s (%s)\n", config.InspectFile, size)
+
+	if ref, err := NewReference(config.InspectFile, ""); err != nil {
+		fmt.Printf("Index:       unknown (%v)\n", err)
+	} else {
+		fmt.Printf("Index:       %s\n", ref.Index)
+		fmt.Printf("Date:        %s (%s)\n", ref.ReferenceGranularity.Display(ref.ReferenceDate), ref.ReferenceGranularity)
+	}
+
+	fmt.Printf("Shards:      %s\n", orUnknown(sum.Shards))
+	fmt.Printf("Replicas:    %s\n", orUnknown(sum.Replicas))
+	fmt.Printf("Documents:   %d\n", sum.Docs)
+
+	if sum.Timestamps == 0 {
+		fmt.Printf("Timestamps:  none (%s)\n", field)
+	} else {
+		fmt.Printf("Timestamps:  %s to %s (%s)", sum.Min.Format(time.RFC3339), sum.Max.Format(time.RFC3339), field)
+
+		if missing := sum.Docs - sum.Timestamps; missing > 0 {
+			fmt.Printf(" (%d documents without one)", missing)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tTYPE")
+
+	for _, f := range sum.Fields {
+		fmt.Fprintf(w, "%s\t%s\n", f.Name, f.Type)
+	}
+
+	if len(sum.Fields) == 0 {
+		fmt.Fprintln(w, "-\t-")
+	}
+
+	w.Flush()
+
+	if sum.Timestamps == 0 {
+		return
+	}
+
+	fmt.Println()
+
+	hours := make([]time.Time, 0, len(sum.Hours))
+	most := 0
+
+	for h, n := range sum.Hours {
+		hours = append(hours, h)
+		if n > most {
+			most = n
+		}
+	}
+
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Before(hours[j]) })
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOUR (UTC)\tDOCUMENTS")
+
+	// only hours with documents are listed, a run of empty hours is a single line so gaps still stand out
+	for i, h := range hours {
+		if i > 0 {
+			switch empty := int(h.Sub(hours[i-1])/time.Hour) - 1; {
+			case empty == 1:
+				fmt.Fprintln(w, "...\t0\t1 empty hour")
+			case empty > 1:
+				fmt.Fprintf(w, "...\t0\t%d empty hours\n", empty)
+			}
+		}
+
+		n := sum.Hours[h]
+
+		bar := n * histogramWidth / most
+		if bar == 0 {
+			bar = 1
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\n", h.Format("2006-01-02 15:00"), n, strings.Repeat("#", bar))
+	}
+
+	w.Flush()
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+
+	return s
+}
@@ -0,0 +1,20 @@
+package app
+
+import "github.com/thetherington/IndexCreator/internal/elastic"
+
+// Options holds the settings shared by every subcommand, it's bound to the persistent flags of the root command
+type Options struct {
+	ElasticURLs []string
+	ElasticAuth elastic.Auth
+	Parallel    int
+	DryRun      bool
+	Progress    string
+	InsiteRoot  string
+	Node        string
+	ElasticDump string
+}
+
+// DefaultInsiteRoot is where inSITE installs its parasite applications, such as the Elasticsearch maintenance program
+const DefaultInsiteRoot = "/opt/evertz/insite/parasite/applications"
+
+var Global Options
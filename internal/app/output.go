@@ -0,0 +1,224 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/chelnak/ysmrr"
+	"github.com/mattn/go-isatty"
+)
+
+// progress output modes (--progress), auto picks spinner on a terminal and plain otherwise
+const (
+	ProgressAuto    = "auto"
+	ProgressSpinner = "spinner"
+	ProgressPlain   = "plain"
+	ProgressJSON    = "json"
+	ProgressNone    = "none"
+)
+
+// repeated counter updates ("Imported 3000 documents") are logged at most this often in plain and json mode
+const progressInterval = 5 * time.Second
+
+// Progress is the status line of a single date, import file or index
+type Progress interface {
+	UpdateMessage(message string)
+	Complete()
+	Error()
+	IsError() bool
+}
+
+// Output displays the status line of every item of a run
+type Output interface {
+	AddSpinner(message string) Progress
+	Start()
+	Stop()
+}
+
+// progressMode resolves auto to spinner on a terminal and plain otherwise
+func progressMode(mode string) string {
+	if mode != ProgressAuto {
+		return mode
+	}
+
+	if isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return ProgressSpinner
+	}
+
+	return ProgressPlain
+}
+
+// newOutput creates the output for the --progress mode
+func newOutput(mode string) Output {
+	mode = progressMode(mode)
+
+	switch mode {
+	case ProgressSpinner:
+		return &spinnerOutput{sm: ysmrr.NewSpinnerManager()}
+	case ProgressNone:
+		return &lineOutput{w: io.Discard}
+	}
+
+	return &lineOutput{w: os.Stdout, json: mode == ProgressJSON}
+}
+
+// Text returns where a command writes the text around the status lines (headings, summary tables).
+// That's stdout for the spinner and plain modes, json and none output only the status lines so the
+// text is dropped
+func (config *Config) Text() io.Writer {
+	switch progressMode(Global.Progress) {
+	case ProgressJSON, ProgressNone:
+		return io.Discard
+	}
+
+	return os.Stdout
+}
+
+// spinnerOutput is the interactive ysmrr display
+type spinnerOutput struct {
+	sm ysmrr.SpinnerManager
+}
+
+func (o *spinnerOutput) AddSpinner(message string) Progress {
+	return o.sm.AddSpinner(message)
+}
+
+func (o *spinnerOutput) Start() {
+	o.sm.Start()
+}
+
+func (o *spinnerOutput) Stop() {
+	o.sm.Stop()
+}
+
+// lineOutput logs every status change as a line of text or JSON, safe for CI logs and nohup
+type lineOutput struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func (o *lineOutput) AddSpinner(message string) Progress {
+	return &lineProgress{out: o, message: message}
+}
+
+func (o *lineOutput) Start() {}
+
+func (o *lineOutput) Stop() {}
+
+// log writes a line, messages are "<item> -- <message>" like the spinners
+func (o *lineOutput) log(status, message string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now().Format(time.RFC3339)
+	item, message, _ := strings.Cut(message, " -- ")
+
+	if !o.json {
+		fmt.Fprintf(o.w, "%s %-8s %s -- %s\n", now, status, item, message)
+		return
+	}
+
+	b, _ := json.Marshal(map[string]string{"time": now, "status": status, "item": item, "message": message})
+	fmt.Fprintf(o.w, "%s\n", b)
+}
+
+// lineProgress is a status line of a lineOutput. Updates are logged after a short settle time so the
+// final message set right before Complete or Error is only logged once, with the final status
+type lineProgress struct {
+	mu      sync.Mutex
+	out     *lineOutput
+	message string
+	pending *time.Timer
+	seq     int
+	logged  time.Time
+	err     bool
+}
+
+// time an update waits for Complete or Error before it's logged as running
+const progressSettle = 100 * time.Millisecond
+
+func (p *lineProgress) UpdateMessage(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// only the counters changed, don't flood the log with every batch
+	if counters(message) == counters(p.message) && (p.pending != nil || time.Since(p.logged) < progressInterval) {
+		p.message = message
+		return
+	}
+
+	// a new step, log the one waiting to settle first
+	if p.pending != nil {
+		p.pending.Stop()
+		p.out.log("running", p.message)
+	}
+
+	p.seq++
+	seq := p.seq
+
+	p.message = message
+	p.pending = time.AfterFunc(progressSettle, func() { p.flush(seq) })
+}
+
+// flush logs the update unless it was superseded while waiting for the lock
+func (p *lineProgress) flush(seq int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending == nil || seq != p.seq {
+		return
+	}
+
+	p.pending = nil
+	p.logged = time.Now()
+	p.out.log("running", p.message)
+}
+
+// finish logs the final message with its status, dropping the pending update of the same message
+func (p *lineProgress) finish(status string) {
+	if p.pending != nil {
+		p.pending.Stop()
+		p.pending = nil
+	}
+
+	p.out.log(status, p.message)
+}
+
+func (p *lineProgress) Complete() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.finish("complete")
+}
+
+func (p *lineProgress) Error() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = true
+	p.finish("error")
+}
+
+func (p *lineProgress) IsError() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// counters strips the digits from a message so progress updates of the same step compare equal
+func counters(message string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return -1
+		}
+		return r
+	}, message)
+}
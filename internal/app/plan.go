@@ -0,0 +1,239 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+	"github.com/thetherington/IndexCreator/internal/transform"
+)
+
+// DryRun reports whether --dry-run was given, commands then print a plan instead of doing any work
+func (config *Config) DryRun() bool {
+	return Global.DryRun
+}
+
+// PlanCreate prints what create would generate, and with importing what create import would import
+func (config *Config) PlanCreate(importing bool) {
+	fmt.Println("Dry run, nothing will be written")
+
+	for _, ref := range config.References {
+		fmt.Println()
+		config.planReference(ref, importing)
+	}
+}
+
+// planReference prints the indices generated from a single reference
+func (config *Config) planReference(ref *Reference, importing bool) {
+	stats, err := helpers.ReadArchiveStats(ref.Filename)
+	if err != nil {
+		fmt.Printf("Reference:   %s (unreadable: %v)\n", ref.Filename, err)
+	} else {
+		fmt.Printf("Reference:   %s (%d documents, %s, %s compressed)\n", ref.Filename, stats.Docs,
+			helpers.HumanBytes(stats.Uncompressed), helpers.HumanBytes(stats.Compressed))
+	}
+
+	opts := config.Transform
+	oldIndex := fmt.Sprintf("%s-%s", ref.Index, ref.FileDate)
+
+	fmt.Printf("Index:       %s -> %s-<date>\n", oldIndex, ref.Index)
+	fmt.Printf("Period:      %s -> %s\n", ref.ReferenceGranularity, ref.Granularity)
+
+	// the date (and hour) can only be swapped in place between daily or hourly indices of the same granularity
+	swap := ref.ReferenceGranularity == ref.Granularity &&
+		(ref.Granularity == helpers.Daily || ref.Granularity == helpers.Hourly)
+
+	shift := fmt.Sprintf("date %s swapped for the new date", ref.ReferenceGranularity.Display(ref.ReferenceDate))
+	if opts.Shift == transform.ShiftDuration || !swap {
+		shift = "shifted by the time between the dates"
+		if opts.Jitter > 0 {
+			shift += fmt.Sprintf(", jitter +/- %s", opts.Jitter)
+		}
+	}
+
+	fmt.Printf("Timestamps:  %s (%s)\n", strings.Join(opts.TimestampFields, ", "), shift)
+	fmt.Printf("Volume:      scale %g, _id %s\n", opts.Scale, opts.IDs)
+
+	if len(ref.IndexDates) > 0 {
+		fmt.Printf("Dates:       %d (%s to %s)\n", len(ref.IndexDates),
+			ref.Granularity.Display(ref.IndexDates[0]), ref.Granularity.Display(ref.IndexDates[len(ref.IndexDates)-1]))
+	} else {
+		fmt.Println("Dates:       none, the range is empty")
+	}
+
+	if importing {
+		fmt.Printf("Target:      %s (%s backend)\n", strings.Join(Global.ElasticURLs, ", "), config.Backend)
+		config.planTools()
+	}
+
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	if importing {
+		fmt.Fprintln(w, "DATE\tINDEX\tTARGET")
+	} else {
+		fmt.Fprintln(w, "DATE\tINDEX\tOUTPUT")
+	}
+
+	for _, dt := range ref.IndexDates {
+		index := ref.indexName(dt)
+
+		if importing {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", ref.Granularity.Display(dt), index, config.indexState(index))
+			continue
+		}
+
+		output := ref.archivePath(dt)
+		if _, err := os.Stat(output); err == nil {
+			output += " (overwrite)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ref.Granularity.Display(dt), index, output)
+	}
+
+	w.Flush()
+
+	if err != nil {
+		return
+	}
+
+	days := int64(len(ref.IndexDates))
+	docs := int64(float64(stats.Docs) * opts.Scale)
+	compressed := int64(float64(stats.Compressed) * opts.Scale)
+	uncompressed := int64(float64(stats.Uncompressed) * opts.Scale)
+
+	fmt.Println()
+	fmt.Printf("Documents:   ~%d per date, ~%d in total\n", docs, docs*days)
+
+	if importing {
+		// each date is generated, extracted, imported and removed before the next one starts
+		fmt.Printf("Disk:        ~%s peak\n", helpers.HumanBytes((compressed+uncompressed)*parallel(len(ref.IndexDates))))
+	} else {
+		fmt.Printf("Disk:        ~%s of import files\n", helpers.HumanBytes(compressed*days))
+	}
+}
+
+// PlanImport prints what import would do with each import file
+func (config *Config) PlanImport() {
+	fmt.Println("Dry run, nothing will be imported")
+	fmt.Println()
+	fmt.Printf("Target:      %s (%s backend)\n", strings.Join(Global.ElasticURLs, ", "), config.Backend)
+	config.planTools()
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tINDEX\tDOCUMENTS\tSIZE\tTARGET\tRESUME")
+
+	var (
+		total int
+		peak  int64
+	)
+
+	for _, f := range config.ImportFiles {
+		index := filepath.Base(strings.TrimSuffix(f, ".tar.gz"))
+
+		docs, size := "?", "?"
+
+		stats, err := helpers.ReadArchiveStats(f)
+		if err == nil {
+			docs, size = fmt.Sprint(stats.Docs), helpers.HumanBytes(stats.Uncompressed)
+			total += stats.Docs
+
+			if stats.Uncompressed > peak {
+				peak = stats.Uncompressed
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", f, index, docs, size, config.indexState(index), config.resumeState(f))
+	}
+
+	w.Flush()
+
+	fmt.Println()
+	fmt.Printf("Documents:   %d\n", total)
+	fmt.Printf("Disk:        ~%s peak for extraction\n", helpers.HumanBytes(peak*parallel(len(config.ImportFiles))))
+}
+
+// PlanExport prints what export would write for each index
+func (config *Config) PlanExport() {
+	fmt.Println("Dry run, nothing will be exported")
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tDOCUMENTS\tOUTPUT")
+
+	for _, index := range config.ExportIndices {
+		docs := "?"
+		if n, err := config.Elastic.Count(index); err == nil {
+			docs = fmt.Sprint(n)
+		}
+
+		output := filepath.Join(config.OutputDir, fmt.Sprintf("%s.tar.gz", index))
+		if _, err := os.Stat(output); err == nil {
+			output += " (overwrite)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", index, docs, output)
+	}
+
+	w.Flush()
+}
+
+// planTools prints the node and elasticdump the elasticdump backend would run
+func (config *Config) planTools() {
+	for _, t := range config.Tools {
+		fmt.Printf("Tool:        %s\n", t)
+	}
+}
+
+// indexState describes whether an index already exists on the cluster
+func (config *Config) indexState(index string) string {
+	exists, err := config.Elastic.Exists(index)
+
+	switch {
+	case err != nil:
+		return fmt.Sprintf("unknown (%v)", err)
+	case exists:
+		return fmt.Sprintf("exists (%s)", config.OnConflict)
+	}
+
+	return "new"
+}
+
+// resumeState describes what a resumed import would skip
+func (config *Config) resumeState(f string) string {
+	if config.State == nil {
+		return "-"
+	}
+
+	var done []string
+	for _, phase := range importPhases {
+		if config.State.Done(f, phase) {
+			done = append(done, phase)
+		}
+	}
+
+	switch {
+	case len(done) == len(importPhases):
+		return "done"
+	case config.State.Offset(f) > 0:
+		return fmt.Sprintf("%s done, data from %d", strings.Join(done, "+"), config.State.Offset(f))
+	case len(done) > 0:
+		return strings.Join(done, "+") + " done"
+	}
+
+	return "-"
+}
+
+// parallel is the number of items worked on at once
+func parallel(items int) int64 {
+	if Global.Parallel < items {
+		return int64(Global.Parallel)
+	}
+
+	return int64(items)
+}
@@ -0,0 +1,40 @@
+package app
+
+// Pool runs queued work items in order with a fixed number of workers
+type Pool struct {
+	queue chan func()
+}
+
+// NewPool starts the workers of a pool able to queue up to items work items without blocking
+func NewPool(workers, items int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &Pool{queue: make(chan func(), items)}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for fn := range p.queue {
+				fn()
+			}
+		}()
+	}
+
+	return p
+}
+
+// NewPool creates a pool sized by the --parallel flag
+func (config *Config) NewPool(items int) *Pool {
+	return NewPool(Global.Parallel, items)
+}
+
+// Queue adds a work item, it will run as soon as a worker is free
+func (p *Pool) Queue(fn func()) {
+	p.queue <- fn
+}
+
+// Close stops the workers once every queued work item has run
+func (p *Pool) Close() {
+	close(p.queue)
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+)
+
+// Reference is an index export that create generates new indices from, several can be created at once
+type Reference struct {
+	Filename             string
+	Index                string
+	FileDate             string
+	ReferenceDate        time.Time
+	ReferenceGranularity helpers.Granularity
+	Granularity          helpers.Granularity
+	IndexDates           []time.Time
+	Spinners             []Progress
+
+	// group summarises the progress of the reference when more than one is created
+	group  Progress
+	mu     sync.Mutex
+	done   int
+	failed bool
+}
+
+// referenceName splits an archive name into the index name and its date
+var referenceName = regexp.MustCompile(`(\.\/)*(.*)(` + helpers.IndexDateExpr.String() + `)`)
+
+// NewReference parses the index name, date and granularity from the archive name. The generated
+// indices have the granularity of the reference unless granularity is given
+func NewReference(filename, granularity string) (*Reference, error) {
+	ref := &Reference{Filename: filename}
+
+	// references in a directory are generated into the working directory like any other
+	for _, match := range referenceName.FindAllStringSubmatch(filepath.Base(filename), -1) {
+		ref.Index = strings.TrimSuffix(match[2], "-")
+		ref.FileDate = match[3]
+	}
+
+	var err error
+
+	// weekly indices are named after their first day so they look daily
+	ref.ReferenceGranularity, err = helpers.DetectGranularity(ref.FileDate)
+	if err != nil {
+		return nil, fmt.Errorf("archive file name does not contain a valid date: %v", err)
+	}
+
+	ref.Granularity = ref.ReferenceGranularity
+
+	if granularity != "" {
+		ref.Granularity, err = helpers.ParseGranularity(granularity)
+		if err != nil {
+			return nil, err
+		}
+
+		if ref.Granularity == helpers.Weekly && ref.ReferenceGranularity == helpers.Daily {
+			ref.ReferenceGranularity = helpers.Weekly
+		}
+	}
+
+	ref.ReferenceDate, err = ref.ReferenceGranularity.Parse(ref.FileDate)
+	if err != nil {
+		return nil, fmt.Errorf("archive file name does not contain a valid date: %v", err)
+	}
+
+	return ref, nil
+}
+
+// referenceFiles expands the create arguments, each a reference archive, a directory of them or a glob.
+// An archive given more than once is only used once
+func referenceFiles(args []string) ([]string, error) {
+	var (
+		files []string
+		seen  = make(map[string]bool)
+	)
+
+	add := func(f string) {
+		if f = filepath.Clean(f); !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+
+		switch {
+		case err == nil && info.IsDir():
+			entries, err := os.ReadDir(arg)
+			if err != nil {
+				return nil, fmt.Errorf("can't read directory %s: %v", arg, err)
+			}
+
+			for _, e := range entries {
+				if !e.IsDir() && strings.HasSuffix(e.Name(), ".tar.gz") && helpers.IndexDateExpr.MatchString(e.Name()) {
+					add(filepath.Join(arg, e.Name()))
+				}
+			}
+
+		case err == nil:
+			add(arg)
+
+		default:
+			matches, gerr := filepath.Glob(arg)
+			if gerr != nil || len(matches) == 0 {
+				return nil, fmt.Errorf("archive file %s does not exist", arg)
+			}
+
+			sort.Strings(matches)
+			for _, m := range matches {
+				add(m)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func (ref *Reference) InitDateRanges(start, end time.Time, days helpers.Weekdays) {
+	for rd := helpers.DateRange(start, end, ref.Granularity); ; {
+		date := rd()
+		if date.IsZero() {
+			break
+		}
+		if !days[date.Weekday()] {
+			continue
+		}
+		ref.IndexDates = append(ref.IndexDates, date)
+	}
+}
+
+// indexName is the name of the index generated for a date
+func (ref *Reference) indexName(dt time.Time) string {
+	return fmt.Sprintf("%s-%s", ref.Index, ref.Granularity.Format(dt))
+}
+
+// label prefixes the spinner messages of a date, the full index name when references are grouped
+func (ref *Reference) label(dt time.Time) string {
+	if ref.group != nil {
+		return ref.indexName(dt)
+	}
+
+	return ref.Granularity.Format(dt)
+}
+
+// archivePath is where the import file generated for a date is written
+func (ref *Reference) archivePath(dt time.Time) string {
+	return filepath.Join(ref.Index, fmt.Sprintf("%s.tar.gz", ref.indexName(dt)))
+}
+
+// finished counts a finished date towards the group line
+func (ref *Reference) finished(err error) {
+	ref.mu.Lock()
+	defer ref.mu.Unlock()
+
+	ref.done++
+	ref.failed = ref.failed || err != nil
+
+	if ref.group == nil {
+		return
+	}
+
+	ref.group.UpdateMessage(fmt.Sprintf("%s -- %d/%d %s indices done", ref.Index, ref.done, len(ref.IndexDates), ref.Granularity))
+
+	if ref.done < len(ref.IndexDates) {
+		return
+	}
+
+	if ref.failed {
+		ref.group.Error()
+		return
+	}
+
+	ref.group.Complete()
+}
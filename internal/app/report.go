@@ -0,0 +1,161 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// status of a report item and of the whole run
+const (
+	ReportOK      = "ok"
+	ReportSkipped = "skipped"
+	ReportFailed  = "failed"
+)
+
+// Report is the machine readable summary of a run written with --report. Items are keyed the
+// same way as the import state (by archive path), a nil Report records nothing
+type Report struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]*ReportItem
+
+	Command  string        `json:"command"`
+	Status   string        `json:"status"`
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Items    []*ReportItem `json:"items"`
+}
+
+// ReportItem is the outcome of a single date or import file
+type ReportItem struct {
+	Date      string   `json:"date,omitempty"`
+	Archive   string   `json:"archive,omitempty"`
+	Index     string   `json:"index"`
+	Status    string   `json:"status"`
+	Phases    []string `json:"phases"`
+	Documents int      `json:"documents"`
+	Bytes     int64    `json:"bytes"`
+	Duration  float64  `json:"duration_seconds"`
+	Note      string   `json:"note,omitempty"`
+	Error     string   `json:"error,omitempty"`
+
+	started time.Time
+}
+
+// NewReport creates the report file upfront so a bad path is caught before any work is done
+func NewReport(path, command string) (*Report, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		path:    path,
+		items:   make(map[string]*ReportItem),
+		Command: command,
+		Started: time.Now(),
+		Items:   []*ReportItem{},
+	}, f.Close()
+}
+
+// Start adds an item to the report, the duration is measured from here
+func (r *Report) Start(key string, item ReportItem) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item.Phases = []string{}
+	item.started = time.Now()
+
+	r.items[key] = &item
+	r.Items = append(r.Items, &item)
+}
+
+// update runs fn on the item while holding the lock, unknown keys are ignored
+func (r *Report) update(key string, fn func(item *ReportItem)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if item, ok := r.items[key]; ok {
+		fn(item)
+	}
+}
+
+// Phase records that a phase was run for the item
+func (r *Report) Phase(key, phase string) {
+	r.update(key, func(item *ReportItem) { item.Phases = append(item.Phases, phase) })
+}
+
+// Documents records the number of documents written for the item
+func (r *Report) Documents(key string, docs int) {
+	r.update(key, func(item *ReportItem) { item.Documents = docs })
+}
+
+// Bytes records the size of the item's import file
+func (r *Report) Bytes(key string, n int64) {
+	r.update(key, func(item *ReportItem) { item.Bytes = n })
+}
+
+// Note records how the item was dealt with, such as an existing index being overwritten
+func (r *Report) Note(key, note string) {
+	r.update(key, func(item *ReportItem) { item.Note = note })
+}
+
+// Skip marks the item as skipped
+func (r *Report) Skip(key, note string) {
+	r.update(key, func(item *ReportItem) {
+		item.Status = ReportSkipped
+		item.Note = note
+		item.Duration = time.Since(item.started).Seconds()
+	})
+}
+
+// Finish marks the item as done or failed, a skipped item stays skipped
+func (r *Report) Finish(key string, err error) {
+	r.update(key, func(item *ReportItem) {
+		item.Duration = time.Since(item.started).Seconds()
+
+		switch {
+		case err != nil:
+			item.Status = ReportFailed
+			item.Error = err.Error()
+		case item.Status == "":
+			item.Status = ReportOK
+		}
+	})
+}
+
+// Write saves the report, the run failed when any item did
+func (r *Report) Write() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Finished = time.Now()
+	r.Status = ReportOK
+
+	for _, item := range r.Items {
+		if item.Status != ReportOK && item.Status != ReportSkipped {
+			r.Status = ReportFailed
+		}
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,254 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thetherington/IndexCreator/internal/transform"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a declarative description of a demo environment, a list of create (and import) runs
+// read from a YAML file by apply
+type Scenario struct {
+	Target   ScenarioTarget  `yaml:"target"`
+	Defaults ScenarioEntry   `yaml:"defaults"`
+	Entries  []ScenarioEntry `yaml:"entries"`
+}
+
+// ScenarioTarget is the cluster the entries are imported into, unset values fall back to the --es-* flags
+type ScenarioTarget struct {
+	URLs     []string `yaml:"urls"`
+	User     string   `yaml:"user"`
+	Password string   `yaml:"password"`
+	APIKey   string   `yaml:"api_key"`
+	CA       string   `yaml:"ca"`
+	Cert     string   `yaml:"cert"`
+	Key      string   `yaml:"key"`
+	Insecure bool     `yaml:"insecure"`
+}
+
+// ScenarioEntry is a single create or create import run, unset values are taken from the defaults
+// of the scenario and then from the defaults of the create import flags
+type ScenarioEntry struct {
+	Name       string   `yaml:"name"`
+	Reference  string   `yaml:"reference"`
+	References []string `yaml:"references"`
+
+	Start       string `yaml:"start"`
+	End         string `yaml:"end"`
+	Last        string `yaml:"last"`
+	Month       string `yaml:"month"`
+	Weekdays    string `yaml:"weekdays"`
+	Granularity string `yaml:"granularity"`
+	MaxDays     int    `yaml:"max_days"`
+
+	Import     *bool  `yaml:"import"`
+	App        string `yaml:"app"`
+	Backend    string `yaml:"backend"`
+	OnConflict string `yaml:"on_conflict"`
+	Report     string `yaml:"report"`
+
+	Transform ScenarioTransform `yaml:"transform"`
+}
+
+// ScenarioTransform mirrors the document transform flags of create
+type ScenarioTransform struct {
+	TimestampFields []string `yaml:"timestamp_fields"`
+	TimestampShift  string   `yaml:"timestamp_shift"`
+	Jitter          string   `yaml:"jitter"`
+	Scale           float64  `yaml:"scale"`
+	IDs             string   `yaml:"ids"`
+}
+
+// builtin are the values of the create import flags when neither the entry nor the defaults set them
+var builtin = ScenarioEntry{
+	MaxDays:    366,
+	App:        "mnt-1",
+	Backend:    BackendNative,
+	OnConflict: ConflictFail,
+	Transform: ScenarioTransform{
+		TimestampFields: []string{transform.DefaultTimestampField},
+		TimestampShift:  transform.ShiftDate,
+		Scale:           1,
+		IDs:             transform.IDKeep,
+	},
+}
+
+// LoadScenario reads a scenario file. Unknown keys are an error so a typo doesn't silently fall back
+// to a default, relative paths (references, reports and the target certificates) are relative to the
+// scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sc Scenario
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("can't parse %s: %v", path, err)
+	}
+
+	if len(sc.Entries) < 1 {
+		return nil, fmt.Errorf("%s has no entries", path)
+	}
+
+	dir := filepath.Dir(path)
+	names := make(map[string]bool)
+
+	resolve := func(p *string) {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+
+	for _, p := range []*string{&sc.Target.CA, &sc.Target.Cert, &sc.Target.Key} {
+		resolve(p)
+	}
+
+	for i := range sc.Entries {
+		e := &sc.Entries[i]
+		e.merge(sc.Defaults)
+		e.merge(builtin)
+
+		if e.Reference != "" {
+			e.References = append([]string{e.Reference}, e.References...)
+		}
+
+		if len(e.References) < 1 {
+			return nil, fmt.Errorf("entry %d of %s has no reference", i+1, path)
+		}
+
+		for j := range e.References {
+			resolve(&e.References[j])
+		}
+
+		resolve(&e.Report)
+
+		if e.Name == "" {
+			e.Name = filepath.Base(e.References[0])
+		}
+
+		if names[e.Name] {
+			return nil, fmt.Errorf("entry name %q is used more than once in %s", e.Name, path)
+		}
+
+		names[e.Name] = true
+	}
+
+	return &sc, nil
+}
+
+// merge fills the values the entry doesn't set from d
+func (e *ScenarioEntry) merge(d ScenarioEntry) {
+	set := func(v *string, d string) {
+		if *v == "" {
+			*v = d
+		}
+	}
+
+	// a date range is taken as a whole, an entry with --last doesn't inherit the default --start
+	if e.Start == "" && e.Last == "" && e.Month == "" {
+		e.Start, e.Last, e.Month = d.Start, d.Last, d.Month
+		set(&e.End, d.End)
+	}
+
+	set(&e.Weekdays, d.Weekdays)
+	set(&e.Granularity, d.Granularity)
+	set(&e.App, d.App)
+	set(&e.Backend, d.Backend)
+	set(&e.OnConflict, d.OnConflict)
+	set(&e.Transform.TimestampShift, d.Transform.TimestampShift)
+	set(&e.Transform.Jitter, d.Transform.Jitter)
+	set(&e.Transform.IDs, d.Transform.IDs)
+
+	if e.MaxDays == 0 {
+		e.MaxDays = d.MaxDays
+	}
+
+	if e.Import == nil {
+		e.Import = d.Import
+	}
+
+	if e.Transform.TimestampFields == nil {
+		e.Transform.TimestampFields = d.Transform.TimestampFields
+	}
+
+	if e.Transform.Scale == 0 {
+		e.Transform.Scale = d.Transform.Scale
+	}
+}
+
+// Importing reports whether the generated indices are imported, entries import unless told otherwise
+func (e *ScenarioEntry) Importing() bool {
+	return e.Import == nil || *e.Import
+}
+
+// Dates returns the date range flags of the entry
+func (e *ScenarioEntry) Dates(yes bool) DateRangeArgs {
+	return DateRangeArgs{
+		Start:       e.Start,
+		End:         e.End,
+		Last:        e.Last,
+		Month:       e.Month,
+		Weekdays:    e.Weekdays,
+		Granularity: e.Granularity,
+		MaxDays:     e.MaxDays,
+		Yes:         yes,
+	}
+}
+
+// TransformOptions returns the document transform flags of the entry
+func (e *ScenarioEntry) TransformOptions() (transform.Options, error) {
+	opts := transform.Options{
+		TimestampFields: e.Transform.TimestampFields,
+		Shift:           e.Transform.TimestampShift,
+		Scale:           e.Transform.Scale,
+		IDs:             e.Transform.IDs,
+	}
+
+	if e.Transform.Jitter != "" {
+		jitter, err := time.ParseDuration(e.Transform.Jitter)
+		if err != nil {
+			return opts, fmt.Errorf("jitter %q is not a duration", e.Transform.Jitter)
+		}
+
+		opts.Jitter = jitter
+	}
+
+	return opts, nil
+}
+
+// Apply sets the connection settings the scenario gives, changed reports whether the flag of a
+// setting was given on the command line, which then wins
+func (t ScenarioTarget) Apply(changed func(flag string) bool) {
+	set := func(flag string, v *string, s string) {
+		if s != "" && !changed(flag) {
+			*v = s
+		}
+	}
+
+	if len(t.URLs) > 0 && !changed("es-url") {
+		Global.ElasticURLs = t.URLs
+	}
+
+	auth := &Global.ElasticAuth
+
+	set("es-user", &auth.Username, t.User)
+	set("es-password", &auth.Password, t.Password)
+	set("es-api-key", &auth.APIKey, t.APIKey)
+	set("es-ca", &auth.CACert, t.CA)
+	set("es-cert", &auth.ClientCert, t.Cert)
+	set("es-key", &auth.ClientKey, t.Key)
+
+	if t.Insecure && !changed("es-insecure") {
+		auth.Insecure = true
+	}
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// State is the progress of an import run. It is saved after every phase and bulk request so
+// an interrupted import can be resumed, a nil State tracks nothing
+type State struct {
+	mu       sync.Mutex
+	path     string
+	Archives map[string]*ArchiveState `json:"archives"`
+}
+
+// ArchiveState records the completed phases of an import file and the number of data documents
+// Elasticsearch has acknowledged
+type ArchiveState struct {
+	Phases map[string]bool `json:"phases"`
+	Offset int             `json:"offset"`
+}
+
+// LoadState reads the state file, a missing file is an empty state
+func LoadState(path string) (*State, error) {
+	st := &State{path: path, Archives: make(map[string]*ArchiveState)}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+
+	if st.Archives == nil {
+		st.Archives = make(map[string]*ArchiveState)
+	}
+
+	return st, nil
+}
+
+func (st *State) archive(f string) *ArchiveState {
+	a, ok := st.Archives[f]
+	if !ok {
+		a = &ArchiveState{Phases: make(map[string]bool)}
+		st.Archives[f] = a
+	}
+
+	return a
+}
+
+// Done reports whether a phase of an import file has completed
+func (st *State) Done(f, phase string) bool {
+	if st == nil {
+		return false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.archive(f).Phases[phase]
+}
+
+// Offset returns the number of data documents already imported from an import file
+func (st *State) Offset(f string) int {
+	if st == nil {
+		return 0
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.archive(f).Offset
+}
+
+// SetOffset records the number of data documents imported from an import file
+func (st *State) SetOffset(f string, offset int) error {
+	if st == nil {
+		return nil
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.archive(f).Offset = offset
+
+	return st.save()
+}
+
+// Complete records a phase of an import file as done
+func (st *State) Complete(f, phase string) error {
+	if st == nil {
+		return nil
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.archive(f).Phases[phase] = true
+
+	return st.save()
+}
+
+// Remove deletes the state file once there is nothing left to resume
+func (st *State) Remove() error {
+	if st == nil {
+		return nil
+	}
+
+	err := os.Remove(st.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// save writes the state to a temporary file first so a crash never leaves a truncated state
+func (st *State) save() error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(st.path), ".state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), st.path)
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// how long a tool gets to print its version
+const toolTimeout = 10 * time.Second
+
+// Tool is an external program used by the elasticdump backend
+type Tool struct {
+	Name    string
+	Path    string
+	From    string
+	Version string
+}
+
+func (t Tool) String() string {
+	return fmt.Sprintf("%s %s (%s, from %s)", t.Name, t.Version, t.Path, t.From)
+}
+
+// AppSource is the From of a tool found in the Elasticsearch maintenance program
+func AppSource(mntAppName string) string {
+	return "--app " + mntAppName
+}
+
+// toolCandidate is a place a tool may be installed
+type toolCandidate struct {
+	path string
+	from string
+}
+
+// findTool returns the first candidate that is an executable file. An explicit path (a flag) is the
+// only candidate when given, otherwise the candidates are tried before PATH
+func findTool(name, explicit string, candidates ...toolCandidate) (Tool, error) {
+	if explicit != "" {
+		if err := executable(explicit); err != nil {
+			return Tool{}, fmt.Errorf("--%s %s %v", name, explicit, err)
+		}
+
+		return Tool{Name: name, Path: explicit, From: "--" + name}, nil
+	}
+
+	var tried []string
+
+	for _, c := range candidates {
+		if err := executable(c.path); err != nil {
+			tried = append(tried, fmt.Sprintf("%s (%v)", c.path, err))
+			continue
+		}
+
+		return Tool{Name: name, Path: c.path, From: c.from}, nil
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return Tool{}, fmt.Errorf("no usable %s, tried %s and PATH", name, strings.Join(tried, ", "))
+	}
+
+	return Tool{Name: name, Path: path, From: "PATH"}, nil
+}
+
+// executable checks the path is a file that can be run
+func executable(path string) error {
+	info, err := os.Stat(path)
+
+	switch {
+	case os.IsNotExist(err):
+		return errors.New("doesn't exist")
+	case err != nil:
+		return err
+	case info.IsDir():
+		return errors.New("is a directory")
+	case info.Mode()&0111 == 0:
+		return errors.New("isn't executable")
+	}
+
+	return nil
+}
+
+// toolVersion runs the command with --version and returns the first line it prints
+func toolVersion(name string, command ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), toolTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, command[0], append(command[1:], "--version")...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s --version failed: %v", name, err)
+	}
+
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if version = strings.TrimSpace(version); version == "" {
+		version = "unknown"
+	}
+
+	return version, nil
+}
+
+// FindTools locates node and elasticdump, checks both run and reads their versions. The elasticdump
+// script is run by node the way the import runs it
+func FindTools(insiteRoot, mntAppName, nodePath, elasticDumpPath string) (Tool, Tool, error) {
+	dir := filepath.Join(insiteRoot, mntAppName, "dependencies")
+	from := AppSource(mntAppName)
+
+	node, err := findTool("node", nodePath, toolCandidate{path: filepath.Join(dir, "node/bin/node"), from: from})
+	if err != nil {
+		return node, Tool{}, err
+	}
+
+	dump, err := findTool("elasticdump", elasticDumpPath, toolCandidate{path: filepath.Join(dir, "elasticdump/bin/elasticdump"), from: from})
+	if err != nil {
+		return node, dump, err
+	}
+
+	node.Version, err = toolVersion(node.Name, node.Path)
+	if err != nil {
+		return node, dump, err
+	}
+
+	dump.Version, err = toolVersion(dump.Name, node.Path, dump.Path)
+	if err != nil {
+		return node, dump, err
+	}
+
+	return node, dump, nil
+}
@@ -1,43 +1,113 @@
 package app
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
+	"github.com/mattn/go-isatty"
+	"github.com/thetherington/IndexCreator/internal/elastic"
 	"github.com/thetherington/IndexCreator/internal/helpers"
+	"github.com/thetherington/IndexCreator/internal/transform"
 )
 
-func (config *Config) InitDateRanges(start, end time.Time) error {
-	y, m, d := start.Date()
-	start = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+// DateRangeArgs are the date selection flags of create and create import
+type DateRangeArgs struct {
+	Start       string
+	End         string
+	Last        string
+	Month       string
+	Weekdays    string
+	Granularity string
+	MaxDays     int
+	Yes         bool
+}
+
+func (config *Config) ValidElasticArgs() bool {
+	auth := Global.ElasticAuth
+
+	if (auth.ClientCert == "") != (auth.ClientKey == "") {
+		fmt.Println("Client certificate and key must be provided together")
+		return false
+	}
+
+	client, err := elastic.NewClient(auth, Global.ElasticURLs...)
+	if err != nil {
+		fmt.Printf("Elasticsearch connection settings are invalid: %v\n", err)
+		return false
+	}
+
+	config.Elastic = client
+
+	return true
+}
+
+// ValidBackendArgs checks the connection, import backend and conflict policy shared by import and create import
+func (config *Config) ValidBackendArgs(mntAppName, backend, onConflict *string) bool {
+	if !config.ValidElasticArgs() {
+		return false
+	}
+
+	switch *backend {
+	case BackendNative:
 
-	y, m, d = end.Date()
-	end = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case BackendElasticDump:
+		// elasticdump only takes headers on its command line, where any local user could read the key
+		if config.Elastic.Auth().APIKey != "" {
+			fmt.Println("The elasticdump backend can't use --es-api-key, use --es-user and --es-password or the native backend")
+			return false
+		}
 
-	for rd := helpers.DateRange(start, end); ; {
-		date := rd()
-		if date.IsZero() {
-			break
+		if !config.ValidToolArgs(*mntAppName) {
+			return false
 		}
-		config.IndexDates = append(config.IndexDates, date)
+
+	default:
+		fmt.Printf("Import backend %q is invalid (native or elasticdump)\n", *backend)
+		return false
 	}
 
-	return nil
+	config.Backend = *backend
+
+	switch *onConflict {
+	case ConflictSkip, ConflictOverwrite, ConflictAppend, ConflictFail:
+		config.OnConflict = *onConflict
+
+	default:
+		fmt.Printf("Conflict policy %q is invalid (skip, overwrite, append or fail)\n", *onConflict)
+		return false
+	}
+
+	return true
 }
 
-func (config *Config) ValidImportArgs(mntAppName *string, args []string) bool {
-	if _, err := os.Stat(fmt.Sprintf("/opt/evertz/insite/parasite/applications/%s", *mntAppName)); os.IsNotExist(err) {
-		fmt.Println("Elastic Maintenance program does not exist")
+// ValidToolArgs finds the node and elasticdump the elasticdump backend runs, from --node and --elasticdump,
+// the Elasticsearch maintenance program or PATH
+func (config *Config) ValidToolArgs(mntAppName string) bool {
+	node, dump, err := FindTools(Global.InsiteRoot, mntAppName, Global.Node, Global.ElasticDump)
+	if err != nil {
+		fmt.Printf("Can't use the elasticdump backend: %v\n", err)
 		return false
 	}
 
-	config.MaintenanceApp = *mntAppName
-	config.NodePath = fmt.Sprintf("/opt/evertz/insite/parasite/applications/%s/dependencies/node/bin/node", *mntAppName)
-	config.ElasticDumpPath = fmt.Sprintf("/opt/evertz/insite/parasite/applications/%s/dependencies/elasticdump/bin/elasticdump", *mntAppName)
+	if node.From == AppSource(mntAppName) || dump.From == AppSource(mntAppName) {
+		config.MaintenanceApp = mntAppName
+	}
+
+	config.NodePath = node.Path
+	config.ElasticDumpPath = dump.Path
+	config.Tools = []Tool{node, dump}
+
+	return true
+}
+
+func (config *Config) ValidImportArgs(mntAppName, backend, onConflict *string, args []string) bool {
+	if !config.ValidBackendArgs(mntAppName, backend, onConflict) {
+		return false
+	}
 
 	// validate something was provided to import
 	if len(args) < 1 {
@@ -54,7 +124,7 @@ func (config *Config) ValidImportArgs(mntAppName *string, args []string) bool {
 	defer file.Close()
 
 	// parse the file name to get the index name and date from the filename
-	re := regexp.MustCompile(`(\.\/)*(.*)(\d{4}\.\d{2}\.\d{2})`)
+	re := helpers.IndexDateExpr
 
 	// check whether what was provided was a directory or a file
 	fileInfo, err := file.Stat()
@@ -90,43 +160,396 @@ func (config *Config) ValidImportArgs(mntAppName *string, args []string) bool {
 	return true
 }
 
-func (config *Config) ValidCreateArgs(startDate, endDate *string, args []string) bool {
-	start, valid := helpers.ValidDateInput(startDate)
-	if !valid {
-		fmt.Println("Start value is invalid")
+func (config *Config) ValidCreateArgs(dates *DateRangeArgs, opts *transform.Options, args []string) bool {
+	if len(args) < 1 {
+		fmt.Println("No export archive provided")
+		return false
+	}
+
+	// each argument is a reference archive, a directory of them or a glob
+	files, err := referenceFiles(args)
+	if err != nil {
+		fmt.Printf("Reference archives are invalid: %v\n", err)
+		return false
+	}
+
+	if len(files) < 1 {
+		fmt.Println("No export archives to use")
+		return false
+	}
+
+	start, end, ok := validDateRange(dates)
+	if !ok {
 		return false
 	}
 
-	end, valid := helpers.ValidDateInput(endDate)
-	if !valid {
-		fmt.Println("End value is invalid")
+	days, err := helpers.ParseWeekdays(dates.Weekdays)
+	if err != nil {
+		fmt.Printf("Weekdays value is invalid: %v\n", err)
 		return false
 	}
 
-	config.InitDateRanges(start, end)
+	if dates.Granularity != "" {
+		if _, err := helpers.ParseGranularity(dates.Granularity); err != nil {
+			fmt.Printf("Granularity value is invalid: %v\n", err)
+			return false
+		}
+	}
+
+	indices := make(map[string]string)
+
+	for _, f := range files {
+		ref, err := NewReference(f, dates.Granularity)
+		if err != nil {
+			fmt.Printf("%s: %v\n", f, err)
+			return false
+		}
+
+		// the indices and import files of both would collide
+		if other, ok := indices[ref.Index]; ok {
+			fmt.Printf("%s and %s are both references for %s\n", other, f, ref.Index)
+			return false
+		}
+
+		indices[ref.Index] = f
+
+		if dates.Weekdays != "" && ref.Granularity != helpers.Hourly && ref.Granularity != helpers.Daily {
+			fmt.Printf("--weekdays can't be used with %s indices (%s)\n", ref.Granularity, f)
+			return false
+		}
+
+		ref.InitDateRanges(start, end, days)
+
+		if len(ref.IndexDates) < 1 {
+			fmt.Printf("No dates in the range match --weekdays (%s)\n", f)
+			return false
+		}
+
+		config.References = append(config.References, ref)
+	}
+
+	if !config.confirmDates(dates) {
+		return false
+	}
+
+	for _, field := range opts.TimestampFields {
+		if strings.TrimSpace(field) == "" {
+			fmt.Println("Timestamp field name can't be empty")
+			return false
+		}
+	}
+
+	if opts.Shift != transform.ShiftDate && opts.Shift != transform.ShiftDuration {
+		fmt.Printf("Timestamp shift %q is invalid (date or duration)\n", opts.Shift)
+		return false
+	}
+
+	if opts.Jitter < 0 || opts.Jitter >= 24*time.Hour {
+		fmt.Println("Jitter must be between 0 and 24h")
+		return false
+	}
+
+	if opts.Jitter > 0 && opts.Shift != transform.ShiftDuration {
+		fmt.Println("Jitter requires --timestamp-shift=duration")
+		return false
+	}
+
+	if opts.Scale <= 0 {
+		fmt.Println("Scale must be greater than 0")
+		return false
+	}
+
+	if opts.IDs != transform.IDKeep && opts.IDs != transform.IDRandom && opts.IDs != transform.IDHash {
+		fmt.Printf("ID strategy %q is invalid (keep, uuid or hash)\n", opts.IDs)
+		return false
+	}
+
+	config.Transform = *opts
+
+	return true
+}
+
+// validDateRange resolves the date flags to the first and last date, the range is either
+// --start (and --end, today by default), --last (ending on --end) or --month
+func validDateRange(dates *DateRangeArgs) (time.Time, time.Time, bool) {
+	var (
+		start, end time.Time
+		err        error
+	)
+
+	set := 0
+	for _, v := range []string{dates.Start, dates.Last, dates.Month} {
+		if v != "" {
+			set++
+		}
+	}
+
+	if set != 1 {
+		fmt.Println("Provide exactly one of --start, --last or --month")
+		return start, end, false
+	}
+
+	if dates.Month != "" {
+		if dates.End != "" {
+			fmt.Println("--end can't be used with --month")
+			return start, end, false
+		}
+
+		start, end, err = helpers.ParseMonth(dates.Month)
+		if err != nil {
+			fmt.Printf("Month value is invalid: %v\n", err)
+			return start, end, false
+		}
+
+		return start, end, true
+	}
+
+	end = helpers.Today()
+	if dates.End != "" {
+		end, err = helpers.ParseDate(dates.End)
+		if err != nil {
+			fmt.Printf("End value is invalid: %v\n", err)
+			return start, end, false
+		}
+	}
+
+	if dates.Last != "" {
+		start, err = helpers.ParseSpan(dates.Last, end)
+		if err != nil {
+			fmt.Printf("Last value is invalid: %v\n", err)
+			return start, end, false
+		}
+
+		return start, end, true
+	}
 
+	start, err = helpers.ParseDate(dates.Start)
+	if err != nil {
+		fmt.Printf("Start value is invalid: %v\n", err)
+		return start, end, false
+	}
+
+	if start.After(end) {
+		fmt.Printf("Start date %s is after end date %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		return start, end, false
+	}
+
+	return start, end, true
+}
+
+// confirmDates asks before creating more than --max-days dates, a mistyped year shouldn't fill the
+// disk or the cluster. Without a terminal to ask on --yes is required
+func (config *Config) confirmDates(dates *DateRangeArgs) bool {
+	// the references can have different granularities, the widest range counts
+	var first, last time.Time
+
+	for _, ref := range config.References {
+		from, to := ref.IndexDates[0], ref.Granularity.Next(ref.IndexDates[len(ref.IndexDates)-1])
+
+		if first.IsZero() || from.Before(first) {
+			first = from
+		}
+		if to.After(last) {
+			last = to
+		}
+	}
+
+	n := int(last.Sub(first).Hours() / 24)
+
+	if dates.MaxDays <= 0 || n <= dates.MaxDays || dates.Yes || config.DryRun() {
+		return true
+	}
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		fmt.Printf("The range spans %d days, more than --max-days %d. Pass --yes to confirm\n", n, dates.MaxDays)
+		return false
+	}
+
+	fmt.Printf("The range spans %d days (%s to %s), more than --max-days %d. Continue? [y/N] ", n,
+		first.Format("2006-01-02"), last.AddDate(0, 0, -1).Format("2006-01-02"), dates.MaxDays)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+
+	return false
+}
+
+func (config *Config) ValidExportArgs(outputDir *string, args []string) bool {
 	if len(args) < 1 {
-		fmt.Println("No export archive provided")
+		fmt.Println("No index pattern provided")
 		return false
 	}
 
-	// Check if the input file exists
-	r, err := os.Open(args[0])
+	if !config.ValidElasticArgs() {
+		return false
+	}
+
+	indices, err := config.Elastic.ListIndices(args[0])
 	if err != nil {
-		fmt.Println("Archive file provided does not exist")
+		fmt.Printf("Unable to list indices: %v\n", err)
 		return false
 	}
-	r.Close()
 
-	config.Filename = r.Name()
+	// the archive layout relies on the date in the index name
+	re := helpers.IndexDateExpr
 
-	// parse the file name to get the index name and date from the filename
-	re := regexp.MustCompile(`(\.\/)*(.*)(\d{4}\.\d{2}\.\d{2})`)
+	for _, index := range indices {
+		if !re.MatchString(index) {
+			fmt.Printf("Skipping %s (no YYYY.MM, YYYY.MM.DD or YYYY.MM.DD.HH date in the index name)\n", index)
+			continue
+		}
+		config.ExportIndices = append(config.ExportIndices, index)
+	}
+
+	if len(config.ExportIndices) < 1 {
+		fmt.Println("No indices to export")
+		return false
+	}
+
+	config.OutputDir = *outputDir
+
+	// a dry run only reports where the files would go
+	if config.DryRun() {
+		return true
+	}
+
+	err = os.MkdirAll(*outputDir, MODE)
+	if err != nil {
+		fmt.Println("Can't create output directory")
+		return false
+	}
+
+	return true
+}
+
+func (config *Config) ValidResumeArgs(stateFile *string, resume *bool) bool {
+	// a dry run of a new import has nothing to resume and must leave the old state file alone
+	if !*resume && config.DryRun() {
+		return true
+	}
+
+	if !*resume {
+		// a new run, forget whatever an earlier run left behind
+		if err := os.Remove(*stateFile); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Can't reset import state file: %v\n", err)
+			return false
+		}
+	}
+
+	state, err := LoadState(*stateFile)
+	if err != nil {
+		fmt.Printf("Can't read import state file: %v\n", err)
+		return false
+	}
+
+	config.State = state
+
+	return true
+}
 
-	for _, match := range re.FindAllStringSubmatch(r.Name(), -1) {
-		config.Index = strings.TrimSuffix(match[2], "-")
-		config.FileDate = match[3]
+func (config *Config) ValidReportArgs(reportFile *string, command string) bool {
+	if *reportFile == "" {
+		return true
 	}
 
+	report, err := NewReport(*reportFile, command)
+	if err != nil {
+		fmt.Printf("Can't create report file: %v\n", err)
+		return false
+	}
+
+	config.Report = report
+
+	return true
+}
+
+func (config *Config) ValidProgressArgs() bool {
+	switch Global.Progress {
+	case ProgressAuto, ProgressSpinner, ProgressPlain, ProgressJSON, ProgressNone:
+		return true
+	}
+
+	fmt.Printf("Progress output %q is invalid (auto, spinner, plain, json or none)\n", Global.Progress)
+	return false
+}
+
+func (config *Config) ValidScenarioArgs(args []string) (*Scenario, bool) {
+	if len(args) < 1 {
+		fmt.Println("No scenario file provided")
+		return nil, false
+	}
+
+	scenario, err := LoadScenario(args[0])
+	if err != nil {
+		fmt.Printf("Scenario file is invalid: %v\n", err)
+		return nil, false
+	}
+
+	return scenario, true
+}
+
+func (config *Config) ValidVerifyArgs(args []string) bool {
+	if len(args) < 1 {
+		fmt.Println("No archive or directory provided")
+		return false
+	}
+
+	info, err := os.Stat(args[0])
+	if err != nil {
+		fmt.Println("Provided File or Directory does not exist")
+		return false
+	}
+
+	if !info.IsDir() {
+		config.VerifyFiles = []string{args[0]}
+	} else {
+		// every archive is checked, a badly named one is reported rather than skipped
+		entries, err := os.ReadDir(args[0])
+		if err != nil {
+			fmt.Println("Can't read directory")
+			return false
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".tar.gz") {
+				config.VerifyFiles = append(config.VerifyFiles, filepath.Join(args[0], e.Name()))
+			}
+		}
+	}
+
+	if len(config.VerifyFiles) < 1 {
+		fmt.Println("No archives to verify")
+		return false
+	}
+
+	config.Checks = make([]*helpers.ArchiveCheck, len(config.VerifyFiles))
+
+	return true
+}
+
+// ValidInspectArgs checks the archive to inspect is a file
+func (config *Config) ValidInspectArgs(args []string) bool {
+	if len(args) < 1 {
+		fmt.Println("No archive provided")
+		return false
+	}
+
+	info, err := os.Stat(args[0])
+	if err != nil {
+		fmt.Println("Provided File does not exist")
+		return false
+	}
+
+	if info.IsDir() {
+		fmt.Println("Provided File is a directory")
+		return false
+	}
+
+	config.InspectFile = args[0]
+
 	return true
 }
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+)
+
+// VerifyArchive checks the i-th archive to verify
+func (config *Config) VerifyArchive(i int, s Progress) {
+	defer config.Wg.Done()
+
+	f := config.VerifyFiles[i]
+
+	s.UpdateMessage(fmt.Sprintf("%s -- Verifying...", f))
+
+	check := helpers.VerifyArchive(f, func(docs int) {
+		s.UpdateMessage(fmt.Sprintf("%s -- Read %d documents", f, docs))
+	})
+
+	config.Checks[i] = check
+
+	if !check.OK() {
+		s.UpdateMessage(fmt.Sprintf("%s -- %d problems", f, len(check.Problems)))
+		s.Error()
+		return
+	}
+
+	s.UpdateMessage(fmt.Sprintf("%s -- OK (%d documents)", f, check.Docs))
+	s.Complete()
+}
+
+// PrintVerify prints the outcome of every archive and then the problems of the ones that failed
+func (config *Config) PrintVerify() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARCHIVE\tSTATUS\tDOCUMENTS\t_INDEX")
+
+	for i, f := range config.VerifyFiles {
+		check := config.Checks[i]
+
+		status := "ok"
+		if !check.OK() {
+			status = "failed"
+		}
+
+		// documents indexed under another name than the archive's are imported under the archive's
+		var indices []string
+		for _, name := range check.IndexNames() {
+			index := fmt.Sprintf("%s (%d)", name, check.Indices[name])
+			if name != check.Index {
+				index += " differs from the archive name"
+			}
+			indices = append(indices, index)
+		}
+
+		if len(indices) == 0 {
+			indices = []string{"-"}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", f, status, check.Docs, strings.Join(indices, ", "))
+	}
+
+	w.Flush()
+
+	for i, f := range config.VerifyFiles {
+		if config.Checks[i].OK() {
+			continue
+		}
+
+		fmt.Println()
+		fmt.Printf("%s:\n", f)
+
+		for _, p := range config.Checks[i].Problems {
+			fmt.Printf("  %s\n", p)
+		}
+	}
+}
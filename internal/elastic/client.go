@@ -0,0 +1,204 @@
+package elastic
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultURL = "http://localhost:9200"
+
+// Auth holds the credentials and TLS settings used to connect to Elasticsearch
+type Auth struct {
+	Username   string
+	Password   string
+	APIKey     string
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	Insecure   bool
+}
+
+// Client is a minimal Elasticsearch REST client covering the handful of APIs needed
+// to recreate an index from an elasticdump export. Requests are spread round-robin over
+// the hosts and fail over to the next host when one can't be reached
+type Client struct {
+	hosts []string
+	next  uint32
+	auth  Auth
+	http  *http.Client
+}
+
+func NewClient(auth Auth, urls ...string) (*Client, error) {
+	tlsConfig, err := auth.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		auth: auth,
+		http: &http.Client{
+			Timeout:   5 * time.Minute,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		},
+	}
+
+	for _, u := range urls {
+		u = strings.TrimSuffix(strings.TrimSpace(u), "/")
+		if u == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid Elasticsearch URL %q", u)
+		}
+
+		c.hosts = append(c.hosts, u)
+	}
+
+	if len(c.hosts) == 0 {
+		return nil, fmt.Errorf("no Elasticsearch URL provided")
+	}
+
+	return c, nil
+}
+
+func (a Auth) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: a.Insecure}
+
+	if a.CACert != "" {
+		pem, err := os.ReadFile(a.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA bundle: %v", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", a.CACert)
+		}
+	}
+
+	if a.ClientCert != "" || a.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(a.ClientCert, a.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate: %v", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// Auth returns the credentials and TLS settings of the client
+func (c *Client) Auth() Auth {
+	return c.auth
+}
+
+// Rotation returns every host in the order one request tries them, starting from the next host in
+// the round-robin rotation. Requests running in parallel each get their own order, so a failover
+// always moves on to a host the request hasn't tried yet
+func (c *Client) Rotation() []string {
+	start := atomic.AddUint32(&c.next, 1) - 1
+	n := uint32(len(c.hosts))
+
+	hosts := make([]string, 0, n)
+	for i := uint32(0); i < n; i++ {
+		hosts = append(hosts, c.hosts[(start+i)%n])
+	}
+
+	return hosts
+}
+
+// do sends a request and returns the response body, any non 2xx status is turned into an error
+// that contains the status and the body Elasticsearch responded with
+func (c *Client) do(method, path string, body []byte, contentType string) ([]byte, error) {
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	// a bulk request may already have been applied when the connection drops, replaying it would
+	// index documents without an _id twice, so it only moves on when the host couldn't be reached
+	replayable := !(method == http.MethodPost && strings.Contains(path, "_bulk"))
+
+	// try each host once starting from the next one in the rotation
+	for _, host := range c.Rotation() {
+		var req *http.Request
+
+		req, err = http.NewRequest(method, host+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		if body != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		switch {
+		case c.auth.APIKey != "":
+			req.Header.Set("Authorization", "ApiKey "+c.auth.APIKey)
+		case c.auth.Username != "":
+			req.SetBasicAuth(c.auth.Username, c.auth.Password)
+		}
+
+		resp, err = c.http.Do(req)
+		if err == nil || (!replayable && !isDialError(err)) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(b))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return b, &StatusError{Method: method, Path: path, Status: resp.StatusCode, Message: fmt.Sprintf("%s %s", resp.Status, msg)}
+	}
+
+	return b, nil
+}
+
+// isDialError reports whether err happened while connecting, before any of the request was sent
+func isDialError(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+// StatusError is returned for any request Elasticsearch answered with a non 2xx status
+type StatusError struct {
+	Method  string
+	Path    string
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Path, strings.TrimSpace(e.Message))
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Status == http.StatusNotFound
+}
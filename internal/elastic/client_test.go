@@ -0,0 +1,156 @@
+package elastic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// deadURL returns the URL of a server that has been shut down, connecting to it is refused
+func deadURL() string {
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+	return s.URL
+}
+
+// dropServer accepts requests and closes the connection without answering, like a host that goes
+// away in the middle of a request
+func dropServer(t *testing.T, hits *int32) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+
+	t.Cleanup(s.Close)
+	return s
+}
+
+// liveServer answers every request with an empty count
+func liveServer(t *testing.T, hits *int32) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Write([]byte(`{"count":0,"errors":false,"items":[]}`))
+	}))
+
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestFailoverParallel(t *testing.T) {
+	var hits int32
+	live := liveServer(t, &hits)
+
+	c, err := NewClient(Auth{}, deadURL(), live.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, requests = 8, 50
+
+	var (
+		wg     sync.WaitGroup
+		failed int32
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < requests; i++ {
+				if _, err := c.Count("logs"); err != nil {
+					atomic.AddInt32(&failed, 1)
+					t.Log(err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if failed > 0 {
+		t.Errorf("%d of %d requests failed with one host down", failed, workers*requests)
+	}
+
+	if hits != workers*requests {
+		t.Errorf("live host answered %d requests, want %d", hits, workers*requests)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	c, err := NewClient(Auth{}, "http://a:9200", "http://b:9200", "http://c:9200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expect := range [][]string{
+		{"http://a:9200", "http://b:9200", "http://c:9200"},
+		{"http://b:9200", "http://c:9200", "http://a:9200"},
+		{"http://c:9200", "http://a:9200", "http://b:9200"},
+		{"http://a:9200", "http://b:9200", "http://c:9200"},
+	} {
+		got := c.Rotation()
+
+		if len(got) != len(expect) {
+			t.Fatalf("Rotation() = %v, want %v", got, expect)
+		}
+
+		for i := range got {
+			if got[i] != expect[i] {
+				t.Fatalf("Rotation() = %v, want %v", got, expect)
+			}
+		}
+	}
+}
+
+func TestFailoverBulk(t *testing.T) {
+	tests := []struct {
+		name     string
+		dropped  bool
+		method   string
+		path     string
+		ok       bool
+		liveHits int32
+	}{
+		{"bulk moves on when the host can't be reached", false, http.MethodPost, "/_bulk", true, 1},
+		{"bulk isn't replayed once it was sent", true, http.MethodPost, "/_bulk", false, 0},
+		{"other requests are replayed", true, http.MethodGet, "/logs/_count", true, 1},
+	}
+
+	for _, tt := range tests {
+		var dropHits, liveHits int32
+
+		first := deadURL()
+		if tt.dropped {
+			first = dropServer(t, &dropHits).URL
+		}
+
+		c, err := NewClient(Auth{}, first, liveServer(t, &liveHits).URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.do(tt.method, tt.path, []byte("{}\n"), "application/x-ndjson")
+
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+
+		if liveHits != tt.liveHits {
+			t.Errorf("%s: live host got %d requests, want %d", tt.name, liveHits, tt.liveHits)
+		}
+
+		if tt.dropped && dropHits != 1 {
+			t.Errorf("%s: first host got %d requests, want 1", tt.name, dropHits)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+)
+
+const scrollKeepAlive = "5m"
+
+// ListIndices returns the sorted names of the indices matching an index pattern
+func (c *Client) ListIndices(pattern string) ([]string, error) {
+	b, err := c.do("GET", fmt.Sprintf("/_cat/indices/%s?format=json&h=index", url.PathEscape(pattern)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Index string `json:"index"`
+	}
+
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(rows))
+	for _, r := range rows {
+		indices = append(indices, r.Index)
+	}
+	sort.Strings(indices)
+
+	return indices, nil
+}
+
+// ExportSettings writes the index settings in the elasticdump settings file format
+func (c *Client) ExportSettings(index string, w io.Writer) error {
+	return c.export(fmt.Sprintf("/%s/_settings", url.PathEscape(index)), w)
+}
+
+// ExportMapping writes the index mapping in the elasticdump mapping file format
+func (c *Client) ExportMapping(index string, w io.Writer) error {
+	return c.export(fmt.Sprintf("/%s/_mapping", url.PathEscape(index)), w)
+}
+
+func (c *Client) export(path string, w io.Writer) error {
+	b, err := c.do("GET", path, nil, "")
+	if err != nil {
+		return err
+	}
+
+	// the responses are already keyed by the index name the same as an elasticdump export
+	var export map[string]json.RawMessage
+	if err := json.Unmarshal(b, &export); err != nil {
+		return err
+	}
+
+	b, err = json.Marshal(export)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// ExportData scrolls through every document of the index and writes them one per line in the
+// elasticdump data file format. progress is called after each page with the documents written so far
+func (c *Client) ExportData(index string, w io.Writer, progress func(docs int)) (int, error) {
+	query, _ := json.Marshal(map[string]interface{}{
+		"size": BulkSize,
+		"sort": []string{"_doc"},
+	})
+
+	b, err := c.do("POST", fmt.Sprintf("/%s/_search?scroll=%s", url.PathEscape(index), scrollKeepAlive), query, "application/json")
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		total    int
+		scrollID string
+	)
+
+	defer func() {
+		if scrollID != "" {
+			body, _ := json.Marshal(map[string]string{"scroll_id": scrollID})
+			c.do("DELETE", "/_search/scroll", body, "application/json")
+		}
+	}()
+
+	for {
+		var page struct {
+			ScrollID string `json:"_scroll_id"`
+			Hits     struct {
+				Hits []Document `json:"hits"`
+			} `json:"hits"`
+		}
+
+		if err := json.Unmarshal(b, &page); err != nil {
+			return total, err
+		}
+
+		scrollID = page.ScrollID
+
+		if len(page.Hits.Hits) == 0 {
+			return total, nil
+		}
+
+		for _, doc := range page.Hits.Hits {
+			line, err := json.Marshal(doc)
+			if err != nil {
+				return total, err
+			}
+
+			if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
+				return total, err
+			}
+		}
+
+		total += len(page.Hits.Hits)
+
+		if progress != nil {
+			progress(total)
+		}
+
+		body, _ := json.Marshal(map[string]string{"scroll": scrollKeepAlive, "scroll_id": scrollID})
+
+		b, err = c.do("POST", "/_search/scroll", body, "application/json")
+		if err != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package elastic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// BulkSize is the number of documents sent in each _bulk request (same as elasticdump --limit)
+const BulkSize = 1000
+
+// settings that are generated by Elasticsearch and are rejected when creating an index
+var readOnlySettings = []string{"creation_date", "uuid", "version", "provided_name"}
+
+// Document is a single line of an elasticdump data file
+type Document struct {
+	Index  string          `json:"_index"`
+	Type   string          `json:"_type,omitempty"`
+	ID     string          `json:"_id"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// ImportSettings reads an elasticdump settings file and creates the index with those settings
+func (c *Client) ImportSettings(index string, r io.Reader) error {
+	var export map[string]struct {
+		Settings map[string]map[string]json.RawMessage `json:"settings"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fmt.Errorf("invalid settings file: %v", err)
+	}
+
+	// the export is keyed by the source index name, there should only be one
+	for _, e := range export {
+		for _, s := range readOnlySettings {
+			delete(e.Settings["index"], s)
+		}
+
+		body, err := json.Marshal(map[string]interface{}{"settings": e.Settings})
+		if err != nil {
+			return err
+		}
+
+		_, err = c.do("PUT", "/"+url.PathEscape(index), body, "application/json")
+		return err
+	}
+
+	return fmt.Errorf("settings file is empty")
+}
+
+// ImportMapping reads an elasticdump mapping file and applies it to the index
+func (c *Client) ImportMapping(index string, r io.Reader) error {
+	var export map[string]struct {
+		Mappings map[string]json.RawMessage `json:"mappings"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fmt.Errorf("invalid mapping file: %v", err)
+	}
+
+	for _, e := range export {
+		path := fmt.Sprintf("/%s/_mapping", url.PathEscape(index))
+
+		// mapping of a document type (Elasticsearch 6)
+		if t, ok := mappingType(e.Mappings); ok {
+			_, err := c.do("PUT", path+"/"+url.PathEscape(t), e.Mappings[t], "application/json")
+			return err
+		}
+
+		// typeless mapping (Elasticsearch 7+)
+		body, err := json.Marshal(e.Mappings)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.do("PUT", path, body, "application/json")
+		return err
+	}
+
+	return fmt.Errorf("mapping file is empty")
+}
+
+// mappingType returns the document type of a typed mapping, one key holding an object that has the
+// properties or dynamic templates. A typeless mapping may have neither (only dynamic or _source)
+func mappingType(mappings map[string]json.RawMessage) (string, bool) {
+	if len(mappings) != 1 {
+		return "", false
+	}
+
+	for t, m := range mappings {
+		var typed map[string]json.RawMessage
+
+		// a typeless mapping with a field named properties looks the same otherwise
+		if t == "properties" || json.Unmarshal(m, &typed) != nil {
+			return "", false
+		}
+
+		_, properties := typed["properties"]
+		_, templates := typed["dynamic_templates"]
+
+		return t, properties || templates
+	}
+
+	return "", false
+}
+
+// ImportData streams an elasticdump data file into the index with the _bulk API, skipping the
+// first offset documents. progress is called after each bulk request with the total number of
+// documents imported so far (including the skipped ones), the import stops when it returns an error
+func (c *Client) ImportData(index string, r io.Reader, offset int, progress func(docs int) error) (int, error) {
+	reader := bufio.NewReader(r)
+
+	var (
+		buf   bytes.Buffer
+		batch int
+	)
+
+	total := offset
+	skip := offset
+
+	flush := func() error {
+		if batch == 0 {
+			return nil
+		}
+
+		if err := c.bulk(buf.Bytes()); err != nil {
+			return fmt.Errorf("bulk import failed after %d documents: %v", total, err)
+		}
+
+		total += batch
+		batch = 0
+		buf.Reset()
+
+		if progress != nil {
+			return progress(total)
+		}
+
+		return nil
+	}
+
+	for line := 1; ; line++ {
+		b, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+
+		switch {
+		case len(bytes.TrimSpace(b)) == 0:
+
+		// already imported by an earlier run
+		case skip > 0:
+			skip--
+
+		default:
+			var doc Document
+			if jerr := json.Unmarshal(b, &doc); jerr != nil {
+				return total, fmt.Errorf("invalid document on line %d: %v", line, jerr)
+			}
+
+			// ES6 indices need the mapping type of the document, without an _id one is generated
+			meta := map[string]string{"_index": index}
+			if doc.Type != "" {
+				meta["_type"] = doc.Type
+			}
+			if doc.ID != "" {
+				meta["_id"] = doc.ID
+			}
+
+			action, _ := json.Marshal(map[string]interface{}{"index": meta})
+
+			buf.Write(action)
+			buf.WriteByte('\n')
+			buf.Write(doc.Source)
+			buf.WriteByte('\n')
+
+			if batch++; batch >= BulkSize {
+				if ferr := flush(); ferr != nil {
+					return total, ferr
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return total, flush()
+}
+
+func (c *Client) bulk(body []byte) error {
+	b, err := c.do("POST", "/_bulk", body, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+
+	if !resp.Errors {
+		return nil
+	}
+
+	// report the first failed document, the rest are usually the same reason
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Error != nil {
+				return fmt.Errorf("document rejected (%d): %s", result.Status, string(result.Error))
+			}
+		}
+	}
+
+	return fmt.Errorf("bulk request reported errors")
+}
@@ -0,0 +1,94 @@
+package elastic
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings string
+		path     string
+		body     string
+	}{
+		{
+			name:     "typeless",
+			mappings: `{"properties":{"message":{"type":"text"}}}`,
+			path:     "/logs/_mapping",
+			body:     `{"properties":{"message":{"type":"text"}}}`,
+		},
+		{
+			name:     "typeless with a field named properties",
+			mappings: `{"properties":{"properties":{"type":"keyword"}}}`,
+			path:     "/logs/_mapping",
+			body:     `{"properties":{"properties":{"type":"keyword"}}}`,
+		},
+		{
+			name:     "typeless with only dynamic templates",
+			mappings: `{"dynamic_templates":[{"strings":{"match_mapping_type":"string","mapping":{"type":"keyword"}}}]}`,
+			path:     "/logs/_mapping",
+			body:     `{"dynamic_templates":[{"strings":{"match_mapping_type":"string","mapping":{"type":"keyword"}}}]}`,
+		},
+		{
+			name:     "typeless with only _source",
+			mappings: `{"_source":{"enabled":false}}`,
+			path:     "/logs/_mapping",
+			body:     `{"_source":{"enabled":false}}`,
+		},
+		{
+			name:     "typeless with dynamic and _source",
+			mappings: `{"_source":{"enabled":true},"dynamic":"strict"}`,
+			path:     "/logs/_mapping",
+			body:     `{"_source":{"enabled":true},"dynamic":"strict"}`,
+		},
+		{
+			name:     "typeless and empty",
+			mappings: `{}`,
+			path:     "/logs/_mapping",
+			body:     `{}`,
+		},
+		{
+			name:     "typed",
+			mappings: `{"_doc":{"properties":{"message":{"type":"text"}}}}`,
+			path:     "/logs/_mapping/_doc",
+			body:     `{"properties":{"message":{"type":"text"}}}`,
+		},
+		{
+			name:     "typed with only dynamic templates",
+			mappings: `{"doc":{"dynamic_templates":[]}}`,
+			path:     "/logs/_mapping/doc",
+			body:     `{"dynamic_templates":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		var path, body string
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			path, body = r.URL.Path, string(b)
+			w.Write([]byte(`{"acknowledged":true}`))
+		}))
+
+		c, err := NewClient(Auth{}, s.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.ImportMapping("logs", strings.NewReader(`{"logs":{"mappings":`+tt.mappings+`}}`))
+		s.Close()
+
+		if err != nil {
+			t.Errorf("%s: ImportMapping() error: %v", tt.name, err)
+			continue
+		}
+
+		if path != tt.path || body != tt.body {
+			t.Errorf("%s: PUT %s %s, want PUT %s %s", tt.name, path, body, tt.path, tt.body)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Exists reports whether the index exists
+func (c *Client) Exists(index string) (bool, error) {
+	_, err := c.do("HEAD", "/"+url.PathEscape(index), nil, "")
+
+	switch {
+	case err == nil:
+		return true, nil
+	case IsNotFound(err):
+		return false, nil
+	}
+
+	return false, err
+}
+
+// Count returns the number of documents in the index
+func (c *Client) Count(index string) (int, error) {
+	b, err := c.do("GET", fmt.Sprintf("/%s/_count", url.PathEscape(index)), nil, "")
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Count int `json:"count"`
+	}
+
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Count, nil
+}
+
+// Delete removes the index along with all of its documents
+func (c *Client) Delete(index string) error {
+	_, err := c.do("DELETE", "/"+url.PathEscape(index), nil, "")
+	return err
+}
@@ -0,0 +1,205 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// relative date (-30d, +2w, -1m, -1y) or span (30d, 2w, 1m, 1y) expressions
+var (
+	dayExpr      = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	relativeExpr = regexp.MustCompile(`^([+-])(\d+)([dwmy])$`)
+	spanExpr     = regexp.MustCompile(`^(\d+)([dwmy])$`)
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Weekdays is a set of days of the week, indexed by time.Weekday
+type Weekdays [7]bool
+
+// AllWeekdays is every day of the week
+var AllWeekdays = Weekdays{true, true, true, true, true, true, true}
+
+// Today is the current local date at midnight UTC, the form every date in a range has
+func Today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ParseDate parses a YYYY-MM-DD date, today, yesterday, tomorrow or a date relative to today
+// such as -30d, +2w, -1m or -1y
+func ParseDate(expr string) (time.Time, error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	today := Today()
+
+	switch expr {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	if m := relativeExpr.FindStringSubmatch(expr); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is out of range", expr)
+		}
+
+		if m[1] == "-" {
+			n = -n
+		}
+
+		return addUnit(today, n, m[3]), nil
+	}
+
+	if !dayExpr.MatchString(expr) {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date, today, yesterday, tomorrow or a relative date like -30d", expr)
+	}
+
+	return ParseDay(expr)
+}
+
+// ParseDay parses a YYYY-MM-DD date, dates that don't exist on the calendar (2023-02-31) are an error
+func ParseDay(expr string) (time.Time, error) {
+	m := dayExpr.FindStringSubmatch(expr)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date", expr)
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+
+	if month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("%q has no month %d", expr, month)
+	}
+
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	days := first.AddDate(0, 1, -1).Day()
+
+	if day < 1 || day > days {
+		return time.Time{}, fmt.Errorf("%q doesn't exist, %s %d has %d days", expr, first.Month(), year, days)
+	}
+
+	return first.AddDate(0, 0, day-1), nil
+}
+
+// ParseSpan parses a span such as 30d, 2w, 3m or 1y and returns the first day of the span
+// ending on (and including) end
+func ParseSpan(expr string, end time.Time) (time.Time, error) {
+	m := spanExpr.FindStringSubmatch(strings.ToLower(strings.TrimSpace(expr)))
+	if m == nil {
+		return time.Time{}, fmt.Errorf("%q is not a span like 30d, 2w, 3m or 1y", expr)
+	}
+
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is out of range", expr)
+	}
+
+	if n < 1 {
+		return time.Time{}, fmt.Errorf("%q is an empty span", expr)
+	}
+
+	return addUnit(end.AddDate(0, 0, 1), -n, m[2]), nil
+}
+
+// ParseMonth parses a YYYY-MM month and returns its first and last day
+func ParseMonth(expr string) (time.Time, time.Time, error) {
+	first, err := time.Parse("2006-01", strings.TrimSpace(expr))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%q is not a YYYY-MM month", expr)
+	}
+
+	return first, first.AddDate(0, 1, -1), nil
+}
+
+// ParseWeekdays parses a comma separated list of days and day ranges such as mon-fri or
+// mon,wed,fri or sat-sun. Ranges may wrap around the end of the week (fri-mon)
+func ParseWeekdays(expr string) (Weekdays, error) {
+	var days Weekdays
+
+	if strings.TrimSpace(expr) == "" {
+		return AllWeekdays, nil
+	}
+
+	for _, part := range strings.Split(strings.ToLower(expr), ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+
+		first, ok := weekday(from)
+		if !ok {
+			return days, fmt.Errorf("%q is not a day of the week (mon, tue, wed, thu, fri, sat or sun)", from)
+		}
+
+		last := first
+		if isRange {
+			if last, ok = weekday(to); !ok {
+				return days, fmt.Errorf("%q is not a day of the week (mon, tue, wed, thu, fri, sat or sun)", to)
+			}
+		}
+
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// weekday accepts the three letter or full name of a day
+func weekday(name string) (time.Weekday, bool) {
+	name = strings.TrimSpace(name)
+	if len(name) < 3 {
+		return 0, false
+	}
+
+	d, ok := weekdayNames[name[:3]]
+	if ok && len(name) > 3 && !strings.EqualFold(name, d.String()) {
+		return 0, false
+	}
+
+	return d, ok
+}
+
+// addUnit moves t by n days, weeks, months or years. Months and years keep the day of the month
+// where possible and otherwise use the last day (-1m from March 31st is the end of February)
+func addUnit(t time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "w":
+		return t.AddDate(0, 0, 7*n)
+	case "m":
+		return addMonths(t, n)
+	case "y":
+		return addMonths(t, 12*n)
+	}
+
+	return t.AddDate(0, 0, n)
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, n, 0)
+	last := first.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
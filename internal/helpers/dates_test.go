@@ -0,0 +1,180 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseDay(t *testing.T) {
+	tests := []struct {
+		expr   string
+		expect time.Time
+		ok     bool
+	}{
+		{"2023-03-15", date("2023-03-15"), true},
+		{"2024-02-29", date("2024-02-29"), true},
+		{"2023-02-29", time.Time{}, false},
+		{"2023-02-31", time.Time{}, false},
+		{"2023-13-01", time.Time{}, false},
+		{"2023-00-10", time.Time{}, false},
+		{"2023-04-00", time.Time{}, false},
+		{"2023-3-15", time.Time{}, false},
+		{"15-03-2023", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDay(tt.expr)
+		if (err == nil) != tt.ok || !got.Equal(tt.expect) {
+			t.Errorf("ParseDay(%q) = %s, %v, want %s (ok %v)", tt.expr, got, err, tt.expect, tt.ok)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	today := Today()
+
+	tests := []struct {
+		expr   string
+		expect time.Time
+		ok     bool
+	}{
+		{"today", today, true},
+		{" Today ", today, true},
+		{"yesterday", today.AddDate(0, 0, -1), true},
+		{"tomorrow", today.AddDate(0, 0, 1), true},
+		{"-30d", today.AddDate(0, 0, -30), true},
+		{"+2w", today.AddDate(0, 0, 14), true},
+		{"-1m", addMonths(today, -1), true},
+		{"-1y", addMonths(today, -12), true},
+		{"2023-03-15", date("2023-03-15"), true},
+		{"2023-02-30", time.Time{}, false},
+		{"30d", time.Time{}, false},
+		{"-30x", time.Time{}, false},
+		{"-99999999999999999999d", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDate(tt.expr)
+		if (err == nil) != tt.ok || !got.Equal(tt.expect) {
+			t.Errorf("ParseDate(%q) = %s, %v, want %s (ok %v)", tt.expr, got, err, tt.expect, tt.ok)
+		}
+	}
+}
+
+func TestParseSpan(t *testing.T) {
+	end := date("2023-03-31")
+
+	tests := []struct {
+		expr   string
+		expect time.Time
+		ok     bool
+	}{
+		// the span includes its last day
+		{"1d", date("2023-03-31"), true},
+		{"7d", date("2023-03-25"), true},
+		{"2w", date("2023-03-18"), true},
+		{"1m", date("2023-03-01"), true},
+		{"3M", date("2023-01-01"), true},
+		{"1y", date("2022-04-01"), true},
+		{"0d", time.Time{}, false},
+		{"-7d", time.Time{}, false},
+		{"7", time.Time{}, false},
+		{"week", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSpan(tt.expr, end)
+		if (err == nil) != tt.ok || !got.Equal(tt.expect) {
+			t.Errorf("ParseSpan(%q) = %s, %v, want %s (ok %v)", tt.expr, got, err, tt.expect, tt.ok)
+		}
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	tests := []struct {
+		expr        string
+		first, last time.Time
+		ok          bool
+	}{
+		{"2023-03", date("2023-03-01"), date("2023-03-31"), true},
+		{"2023-02", date("2023-02-01"), date("2023-02-28"), true},
+		{"2024-02", date("2024-02-01"), date("2024-02-29"), true},
+		{"2023-04", date("2023-04-01"), date("2023-04-30"), true},
+		{"2023-13", time.Time{}, time.Time{}, false},
+		{"2023-03-01", time.Time{}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		first, last, err := ParseMonth(tt.expr)
+		if (err == nil) != tt.ok || !first.Equal(tt.first) || !last.Equal(tt.last) {
+			t.Errorf("ParseMonth(%q) = %s, %s, %v, want %s, %s (ok %v)", tt.expr, first, last, err, tt.first, tt.last, tt.ok)
+		}
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	days := func(ds ...time.Weekday) Weekdays {
+		var w Weekdays
+		for _, d := range ds {
+			w[d] = true
+		}
+		return w
+	}
+
+	tests := []struct {
+		expr   string
+		expect Weekdays
+		ok     bool
+	}{
+		{"", AllWeekdays, true},
+		{"mon-fri", days(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday), true},
+		{"mon,wed,fri", days(time.Monday, time.Wednesday, time.Friday), true},
+		{"sat-sun", days(time.Saturday, time.Sunday), true},
+		{"fri-mon", days(time.Friday, time.Saturday, time.Sunday, time.Monday), true},
+		{"Monday, Tuesday", days(time.Monday, time.Tuesday), true},
+		{"wed-wed", days(time.Wednesday), true},
+		{"sun-sat", AllWeekdays, true},
+		{"mo", Weekdays{}, false},
+		{"mon-funday", Weekdays{}, false},
+		{"monx", Weekdays{}, false},
+		{"mon,,fri", Weekdays{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseWeekdays(tt.expr)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.expect) {
+			t.Errorf("ParseWeekdays(%q) = %v, %v, want %v (ok %v)", tt.expr, got, err, tt.expect, tt.ok)
+		}
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		from   time.Time
+		n      int
+		expect time.Time
+	}{
+		{date("2023-03-15"), 1, date("2023-04-15")},
+		{date("2023-03-31"), -1, date("2023-02-28")},
+		{date("2024-03-31"), -1, date("2024-02-29")},
+		{date("2023-01-31"), 1, date("2023-02-28")},
+		{date("2023-05-31"), 1, date("2023-06-30")},
+		{date("2024-02-29"), 12, date("2025-02-28")},
+		{date("2023-03-15"), -15, date("2021-12-15")},
+	}
+
+	for _, tt := range tests {
+		if got := addMonths(tt.from, tt.n); !got.Equal(tt.expect) {
+			t.Errorf("addMonths(%s, %d) = %s, want %s", tt.from.Format("2006-01-02"), tt.n, got.Format("2006-01-02"), tt.expect.Format("2006-01-02"))
+		}
+	}
+}
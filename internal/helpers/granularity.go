@@ -0,0 +1,113 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Granularity is the period of time covered by a single index
+type Granularity string
+
+const (
+	Hourly  Granularity = "hourly"
+	Daily   Granularity = "daily"
+	Weekly  Granularity = "weekly"
+	Monthly Granularity = "monthly"
+)
+
+// IndexDateExpr matches the date in an index or import file name: YYYY.MM (monthly),
+// YYYY.MM.DD (daily or weekly) or YYYY.MM.DD.HH (hourly)
+var IndexDateExpr = regexp.MustCompile(`\d{4}\.\d{2}(?:\.\d{2}){0,2}`)
+
+// ParseGranularity validates a --granularity value
+func ParseGranularity(name string) (Granularity, error) {
+	switch g := Granularity(name); g {
+	case Hourly, Daily, Weekly, Monthly:
+		return g, nil
+	}
+
+	return "", fmt.Errorf("%q is not a granularity (hourly, daily, weekly or monthly)", name)
+}
+
+// DetectGranularity works out the granularity from the date of an index name, weekly indices are
+// named after their first day so they can't be told apart from daily ones
+func DetectGranularity(date string) (Granularity, error) {
+	for _, g := range []Granularity{Hourly, Daily, Monthly} {
+		if len(date) == len(g.Layout()) {
+			return g, nil
+		}
+	}
+
+	return "", fmt.Errorf("%q is not a YYYY.MM, YYYY.MM.DD or YYYY.MM.DD.HH date", date)
+}
+
+// Layout is the time layout of the date in the index name
+func (g Granularity) Layout() string {
+	switch g {
+	case Hourly:
+		return "2006.01.02.15"
+	case Monthly:
+		return "2006.01"
+	}
+
+	return "2006.01.02"
+}
+
+// Format returns the date used in the name of the index starting at t
+func (g Granularity) Format(t time.Time) string {
+	return t.Format(g.Layout())
+}
+
+// Display formats the start of a period for people to read
+func (g Granularity) Display(t time.Time) string {
+	switch g {
+	case Hourly:
+		return t.Format("2006-01-02 15:00")
+	case Monthly:
+		return t.Format("2006-01")
+	}
+
+	return t.Format("2006-01-02")
+}
+
+// Parse reads the date of an index name, dates that don't exist on the calendar are an error
+func (g Granularity) Parse(date string) (time.Time, error) {
+	t, err := time.Parse(g.Layout(), date)
+	if err != nil || g.Format(t) != date {
+		return time.Time{}, fmt.Errorf("%q is not a valid %s date", date, g.Layout())
+	}
+
+	return t, nil
+}
+
+// Truncate returns the start of the period t falls in, weeks start on Monday
+func (g Granularity) Truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+
+	switch g {
+	case Hourly:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, time.UTC)
+	case Weekly:
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Monthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the start of the period after the one starting at t
+func (g Granularity) Next(t time.Time) time.Time {
+	switch g {
+	case Hourly:
+		return t.Add(time.Hour)
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	case Monthly:
+		return t.AddDate(0, 1, 0)
+	}
+
+	return t.AddDate(0, 0, 1)
+}
@@ -12,9 +12,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/chelnak/ysmrr"
 )
 
 // Untar takes a destination path and a reader; a tar reader loops over the tarfile
@@ -28,8 +27,6 @@ func Untar(dst string, r io.Reader) error {
 
 	tr := tar.NewReader(gzr)
 
-	re := regexp.MustCompile(`\d{4}\.\d{2}\.\d{2}`)
-
 	for {
 		header, err := tr.Next()
 
@@ -49,8 +46,8 @@ func Untar(dst string, r io.Reader) error {
 		}
 
 		// find the date value in the file name and replace it with the value in the paths
-		dir := re.FindStringSubmatch(dst)[0]
-		newHeaderName := re.ReplaceAll([]byte(header.Name), []byte(dir))
+		dir := IndexDateExpr.FindString(filepath.Base(dst))
+		newHeaderName := IndexDateExpr.ReplaceAll([]byte(header.Name), []byte(dir))
 
 		// the target location where the dir/file should be created
 		target := filepath.Join(dst, string(newHeaderName))
@@ -86,140 +83,285 @@ func Untar(dst string, r io.Reader) error {
 	}
 }
 
-// Tar takes a source and variable writers and walks 'source' writing each file
-// found to the tar writer; the purpose for accepting multiple writers is to allow
-// for multiple outputs (for example a file, or md5 hash)
-func Tar(src string, writers ...io.Writer) error {
-
-	// ensure the src actually exists before trying to tar it
-	if _, err := os.Stat(src); err != nil {
-		return fmt.Errorf("unable to tar files - %v", err.Error())
-	}
-
-	mw := io.MultiWriter(writers...)
-
-	gzw := gzip.NewWriter(mw)
-	defer gzw.Close()
+// TarEntry is a single file to be written by TarEntries
+type TarEntry struct {
+	Name string
+	Size int64
+	Body io.Reader
+}
 
+// TarEntries writes each entry (in order) into a gzip compressed tar archive; the contents
+// don't need to exist on disk as long as the size of each entry is known upfront
+func TarEntries(w io.Writer, entries ...TarEntry) error {
+	gzw := gzip.NewWriter(w)
 	tw := tar.NewWriter(gzw)
-	defer tw.Close()
 
-	// walk path
-	return filepath.Walk(src, func(file string, fi os.FileInfo, err error) error {
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:     e.Name,
+			Mode:     0644,
+			Size:     e.Size,
+			ModTime:  time.Now(),
+			Typeflag: tar.TypeReg,
+		}
 
-		// return on any error
-		if err != nil {
+		if err := tw.WriteHeader(header); err != nil {
 			return err
 		}
 
-		// return on non-regular files (thanks to [kumo](https://medium.com/@komuw/just-like-you-did-fbdd7df829d3) for this suggested update)
-		if !fi.Mode().IsRegular() {
-			return nil
+		if _, err := io.Copy(tw, e.Body); err != nil {
+			return err
 		}
+	}
 
-		// create a new dir/file header
-		header, err := tar.FileInfoHeader(fi, fi.Name())
-		if err != nil {
-			return err
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gzw.Close()
+}
+
+// ArchiveStats is a summary of an import file
+type ArchiveStats struct {
+	Compressed   int64
+	Uncompressed int64
+	Docs         int
+}
+
+// ReadArchiveStats streams an import file counting its size and the lines of the data file
+func ReadArchiveStats(file string) (ArchiveStats, error) {
+	var stats ArchiveStats
+
+	r, err := os.Open(file)
+	if err != nil {
+		return stats, err
+	}
+	defer r.Close()
+
+	info, err := r.Stat()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Compressed = info.Size()
+
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return stats, err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+
+	for {
+		header, err := tr.Next()
+
+		switch {
+		case err == io.EOF:
+			return stats, nil
+
+		case err != nil:
+			return stats, err
+
+		case header == nil || header.Typeflag != tar.TypeReg:
+			continue
 		}
 
-		// update the name to correctly reflect the desired destination when untaring
-		header.Name = strings.TrimPrefix(strings.Replace(file, src, "", -1), string(filepath.Separator))
+		stats.Uncompressed += header.Size
 
-		// write the header
-		if err := tw.WriteHeader(header); err != nil {
-			return err
+		if !strings.HasSuffix(header.Name, "-data.json") {
+			continue
 		}
 
-		// open files for taring
-		f, err := os.Open(file)
-		if err != nil {
-			return err
+		scanner := bufio.NewScanner(tr)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+		for scanner.Scan() {
+			if len(strings.TrimSpace(scanner.Text())) > 0 {
+				stats.Docs++
+			}
 		}
 
-		// copy file data into tar writer
-		if _, err := io.Copy(tw, f); err != nil {
-			return err
+		if err := scanner.Err(); err != nil {
+			return stats, err
 		}
+	}
+}
+
+// HumanBytes formats a byte count with a binary unit
+func HumanBytes(n int64) string {
+	const unit = 1024
 
-		// manually close here after each file operation; defering would cause each file close
-		// to wait until all operations have completed.
-		f.Close()
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
 
-		return nil
-	})
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
-func DateRange(start, end time.Time) func() time.Time {
-	y, m, d := start.Date()
-	start = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+// DateRange steps through the start of every period from the one start falls in up to the last
+// one starting on or before the end day
+func DateRange(start, end time.Time, g Granularity) func() time.Time {
+	start = g.Truncate(start)
 
-	y, m, d = end.Date()
-	end = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d := end.Date()
+	end = time.Date(y, m, d, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
 
 	return func() time.Time {
-		if start.After(end) {
+		if !start.Before(end) {
 			return time.Time{}
 		}
 		date := start
-		start = start.AddDate(0, 0, 1)
+		start = g.Next(start)
 		return date
 	}
 }
 
-func SedReplace(path, original, new string) error {
-	files, err := os.ReadDir(path)
-	if err != nil {
-		return err
-	}
+// number of output lines kept to explain an elasticdump failure and how much of each is kept
+const (
+	elasticDumpTail    = 5
+	elasticDumpLineMax = 300
+)
+
+// ElasticDumpError is returned when elasticdump exits with a non zero status
+type ElasticDumpError struct {
+	ExitCode int
+	Output   []string
+}
 
-	sed_replace := fmt.Sprintf("s/%s/%s/g", original, new)
+func (e *ElasticDumpError) Error() string {
+	if len(e.Output) == 0 {
+		return fmt.Sprintf("elasticdump exited with status %d", e.ExitCode)
+	}
+	return fmt.Sprintf("elasticdump exited with status %d: %s", e.ExitCode, strings.Join(e.Output, " | "))
+}
 
-	for _, item := range files {
-		command := exec.Command("sed", "-i", sed_replace, filepath.Join(path, item.Name()))
+// node error codes of a host that can't be reached, as opposed to one that answered with an error
+var unreachableCodes = []string{"ECONNREFUSED", "ECONNRESET", "EHOSTUNREACH", "ENETUNREACH", "ENOTFOUND", "ETIMEDOUT", "EAI_AGAIN", "socket hang up"}
 
-		err := command.Run()
-		if err != nil {
-			return err
+// Unreachable reports whether elasticdump failed because it couldn't connect to Elasticsearch
+func (e *ElasticDumpError) Unreachable() bool {
+	for _, line := range e.Output {
+		for _, code := range unreachableCodes {
+			if strings.Contains(line, code) {
+				return true
+			}
 		}
 	}
 
-	return nil
+	return false
 }
 
-func ValidDateInput(date *string) (time.Time, bool) {
-	re := regexp.MustCompile(`\d{4}\-\d{2}\-\d{2}`)
-
-	if re.MatchString(*date) {
+// tail keeps the last n lines written to it
+type tail struct {
+	mu    sync.Mutex
+	n     int
+	lines []string
+}
 
-		d := strings.Split(*date, "-")
+func (t *tail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-		year, _ := strconv.Atoi(d[0])
-		month, _ := strconv.Atoi(d[1])
-		day, _ := strconv.Atoi(d[2])
+	if line = strings.TrimSpace(line); line == "" {
+		return
+	}
 
-		if month > 12 || day > 32 {
-			return time.Now(), false
-		}
+	// a bulk error can dump whole documents on a single line
+	if len(line) > elasticDumpLineMax {
+		line = line[:elasticDumpLineMax] + "..."
+	}
 
-		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), true
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.n {
+		t.lines = t.lines[len(t.lines)-t.n:]
 	}
+}
+
+func (t *tail) get() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	return time.Now(), false
+	return append([]string(nil), t.lines...)
 }
 
-func ElasticDumpRun(node_path string, args []string, s *ysmrr.Spinner, f string) error {
+// MessageUpdater is the status line ElasticDumpRun reports the elasticdump progress to
+type MessageUpdater interface {
+	UpdateMessage(message string)
+}
+
+// elasticdump logs each batch it writes as "sent 1000 objects to destination elasticsearch, wrote 998"
+var elasticDumpWrote = regexp.MustCompile(`sent (\d+) objects to destination \w+, wrote (\d+)`)
+
+// ElasticDumpRun runs elasticdump with node and reports its progress to s, prefixed with f. progress,
+// when given, is called after each batch with the number of documents sent (read from the input, as
+// batches are written one at a time) and written so far. elasticdump is stopped when it returns an error
+func ElasticDumpRun(node_path string, args, env []string, s MessageUpdater, f string, progress func(sent, written int) error) error {
 
 	command := exec.Command(node_path, args...)
-	pipe, _ := command.StdoutPipe()
+	command.Env = append(os.Environ(), env...)
+
+	pipe, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
 
-	command.Start()
+	errPipe, err := command.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := command.Start(); err != nil {
+		return err
+	}
+
+	stdout := &tail{n: elasticDumpTail}
+	stderr := &tail{n: elasticDumpTail}
+
+	// stderr has to be drained while stdout is read or elasticdump could block on a full pipe
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		scanner := bufio.NewScanner(errPipe)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+		for scanner.Scan() {
+			stderr.add(scanner.Text())
+		}
+
+		// keep draining after a line too long for the scanner, elasticdump would block otherwise
+		io.Copy(io.Discard, errPipe)
+	}()
 
 	reader := bufio.NewReader(pipe)
 	line, err := reader.ReadString('\n')
 
+	var (
+		sent, wrote int
+		perr        error
+	)
+
 	for err == nil {
 		line = strings.TrimSuffix(line, "\n")
+		stdout.add(line)
+
+		if m := elasticDumpWrote.FindStringSubmatch(line); m != nil && progress != nil && perr == nil {
+			n, _ := strconv.Atoi(m[1])
+			sent += n
+			n, _ = strconv.Atoi(m[2])
+			wrote += n
+
+			if perr = progress(sent, wrote); perr != nil {
+				command.Process.Kill()
+			}
+		}
 
 		if strings.Contains(args[3], "data") {
 			if strings.Contains(line, "offset") && strings.Contains(line, "|") {
@@ -236,7 +378,26 @@ func ElasticDumpRun(node_path string, args []string, s *ysmrr.Spinner, f string)
 		line, err = reader.ReadString('\n')
 	}
 
-	command.Wait()
+	<-done
+
+	err = command.Wait()
+	if perr != nil {
+		return perr
+	}
+
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return err
+		}
+
+		output := stderr.get()
+		if len(output) == 0 {
+			output = stdout.get()
+		}
+
+		return &ElasticDumpError{ExitCode: exitErr.ExitCode(), Output: output}
+	}
 
 	return nil
 }
@@ -0,0 +1,205 @@
+package helpers
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// number of bad data lines reported one by one, the rest are only counted
+const badLinesShown = 5
+
+// ArchiveCheck is the outcome of VerifyArchive
+type ArchiveCheck struct {
+	Index    string
+	Docs     int
+	Indices  map[string]int
+	Problems []string
+}
+
+// OK reports whether the archive can be imported as is
+func (c *ArchiveCheck) OK() bool {
+	return len(c.Problems) == 0
+}
+
+// IndexNames returns the distinct _index values of the data file, sorted
+func (c *ArchiveCheck) IndexNames() []string {
+	names := make([]string, 0, len(c.Indices))
+	for name := range c.Indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (c *ArchiveCheck) problem(format string, a ...interface{}) {
+	c.Problems = append(c.Problems, fmt.Sprintf(format, a...))
+}
+
+// elasticdump data record, only the fields needed to import it
+type record struct {
+	Index  *string          `json:"_index"`
+	Source *json.RawMessage `json:"_source"`
+}
+
+// VerifyArchive streams an import file and checks it holds exactly the settings, mapping and data
+// files of the index in its name, that the settings and mapping are JSON and that every line of the
+// data file is an elasticdump record. progress is called with the documents read so far
+func VerifyArchive(file string, progress func(docs int)) *ArchiveCheck {
+	check := &ArchiveCheck{Indices: make(map[string]int)}
+
+	base := filepath.Base(file)
+	check.Index = strings.TrimSuffix(base, ".tar.gz")
+
+	if !strings.HasSuffix(base, ".tar.gz") {
+		check.problem("file name doesn't end in .tar.gz")
+	}
+
+	if date := IndexDateExpr.FindString(check.Index); date == "" || !strings.HasSuffix(check.Index, "-"+date) {
+		check.problem("file name doesn't end in a YYYY.MM, YYYY.MM.DD or YYYY.MM.DD.HH date")
+	} else if g, err := DetectGranularity(date); err != nil {
+		check.problem("file name date: %v", err)
+	} else if _, err := g.Parse(date); err != nil {
+		check.problem("file name date: %v", err)
+	}
+
+	r, err := os.Open(file)
+	if err != nil {
+		check.problem("%v", err)
+		return check
+	}
+	defer r.Close()
+
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		check.problem("not a gzip file: %v", err)
+		return check
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	found := make(map[string]bool)
+
+	for {
+		header, err := tr.Next()
+
+		switch {
+		case err == io.EOF:
+			// the gzip checksum is only checked once the stream is read to the end
+			if _, err := io.Copy(io.Discard, gzr); err != nil {
+				check.problem("archive is truncated or corrupt: %v", err)
+			}
+
+			for _, kind := range []string{"settings", "mapping", "data"} {
+				if !found[kind] {
+					check.problem("the %s file is missing", kind)
+				}
+			}
+			return check
+
+		case err != nil:
+			check.problem("archive is truncated or corrupt: %v", err)
+			return check
+
+		case header == nil || header.Typeflag == tar.TypeDir:
+			continue
+
+		case header.Typeflag != tar.TypeReg:
+			check.problem("%s is not a regular file", header.Name)
+			continue
+		}
+
+		kind := ""
+		for _, k := range []string{"settings", "mapping", "data"} {
+			if strings.HasSuffix(header.Name, fmt.Sprintf("-%s.json", k)) {
+				kind = k
+			}
+		}
+
+		if kind == "" {
+			check.problem("unexpected file %s", header.Name)
+			continue
+		}
+
+		// the import looks for the files under the name of the archive
+		if want := fmt.Sprintf("%s-%s.json", check.Index, kind); header.Name != want {
+			check.problem("%s should be named %s", header.Name, want)
+		}
+
+		if found[kind] {
+			check.problem("%s is a second %s file", header.Name, kind)
+		}
+
+		found[kind] = true
+
+		if kind != "data" {
+			if err := json.NewDecoder(tr).Decode(new(map[string]json.RawMessage)); err != nil {
+				check.problem("%s is not valid JSON: %v", header.Name, err)
+			}
+			continue
+		}
+
+		if err := verifyData(tr, header.Name, check, progress); err != nil {
+			check.problem("archive is truncated or corrupt: %v", err)
+			return check
+		}
+	}
+}
+
+// verifyData checks each line of the data file is an elasticdump record and counts the documents
+// per _index. Only reading errors are returned, bad lines are problems of the check
+func verifyData(r io.Reader, name string, check *ArchiveCheck, progress func(docs int)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	line, bad := 0, 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var rec record
+
+		err := json.Unmarshal([]byte(text), &rec)
+
+		switch {
+		case err != nil:
+		case rec.Index == nil:
+			err = fmt.Errorf("no _index")
+		case rec.Source == nil:
+			err = fmt.Errorf("no _source")
+		}
+
+		if err != nil {
+			if bad++; bad <= badLinesShown {
+				check.problem("%s line %d is not an elasticdump record: %v", name, line, err)
+			}
+			continue
+		}
+
+		check.Docs++
+		check.Indices[*rec.Index]++
+
+		if progress != nil && check.Docs%10000 == 0 {
+			progress(check.Docs)
+		}
+	}
+
+	if bad > badLinesShown {
+		check.problem("%s has %d more lines that are not elasticdump records", name, bad-badLinesShown)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,268 @@
+package transform
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary describes the contents of an import archive
+type Summary struct {
+	Shards   string
+	Replicas string
+	Fields   []Field
+	Docs     int
+
+	// Timestamps is the number of documents with a readable timestamp, Min and Max are the
+	// earliest and latest of them and Hours counts them per hour (UTC)
+	Timestamps int
+	Min        time.Time
+	Max        time.Time
+	Hours      map[time.Time]int
+}
+
+// Field is a mapped field, nested objects are flattened to dotted names
+type Field struct {
+	Name string
+	Type string
+}
+
+// Inspect streams an import archive and summarises its settings, mapping and documents, field is
+// the timestamp field the documents are counted by
+func Inspect(src io.Reader, field string) (*Summary, error) {
+	sum := &Summary{Hours: make(map[time.Time]int)}
+
+	gzr, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+
+	for {
+		header, err := tr.Next()
+
+		switch {
+		case err == io.EOF:
+			return sum, nil
+
+		case err != nil:
+			return nil, err
+
+		case header == nil || header.Typeflag != tar.TypeReg:
+			continue
+		}
+
+		switch Kind(header.Name) {
+		case "settings":
+			err = sum.settings(tr)
+
+		case "mapping":
+			err = sum.mapping(tr)
+
+		case "data":
+			err = sum.data(tr, field)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", header.Name, err)
+		}
+	}
+}
+
+// settings reads the shard and replica count of an elasticdump settings file, the settings may be
+// nested ("index": {"number_of_shards": ...}) or flat ("index.number_of_shards": ...)
+func (sum *Summary) settings(r io.Reader) error {
+	var export map[string]struct {
+		Settings map[string]json.RawMessage `json:"settings"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return err
+	}
+
+	for _, index := range export {
+		var nested map[string]interface{}
+		json.Unmarshal(index.Settings["index"], &nested)
+
+		for _, s := range []struct {
+			key   string
+			value *string
+		}{{"number_of_shards", &sum.Shards}, {"number_of_replicas", &sum.Replicas}} {
+			if v, ok := nested[s.key]; ok {
+				*s.value = fmt.Sprint(v)
+			}
+
+			var flat interface{}
+			if json.Unmarshal(index.Settings["index."+s.key], &flat) == nil && flat != nil {
+				*s.value = fmt.Sprint(flat)
+			}
+		}
+	}
+
+	return nil
+}
+
+// mapping lists the fields of an elasticdump mapping file, with or without a mapping type level
+func (sum *Summary) mapping(r io.Reader) error {
+	var export map[string]struct {
+		Mappings map[string]json.RawMessage `json:"mappings"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return err
+	}
+
+	for _, index := range export {
+		properties, ok := index.Mappings["properties"]
+
+		// older clusters have a mapping type (_doc, doc, ...) holding the properties
+		if !ok {
+			for _, typed := range index.Mappings {
+				var m map[string]json.RawMessage
+				if json.Unmarshal(typed, &m) == nil && m["properties"] != nil {
+					properties = m["properties"]
+					break
+				}
+			}
+		}
+
+		if properties != nil {
+			if err := sum.fields("", properties); err != nil {
+				return err
+			}
+		}
+	}
+
+	sort.Slice(sum.Fields, func(i, j int) bool { return sum.Fields[i].Name < sum.Fields[j].Name })
+
+	return nil
+}
+
+// fields flattens the properties of a mapping, multi-fields (such as a keyword sub field) included
+func (sum *Summary) fields(prefix string, properties json.RawMessage) error {
+	var props map[string]struct {
+		Type       string          `json:"type"`
+		Properties json.RawMessage `json:"properties"`
+		Fields     json.RawMessage `json:"fields"`
+	}
+
+	if err := json.Unmarshal(properties, &props); err != nil {
+		return err
+	}
+
+	for name, p := range props {
+		name = prefix + name
+
+		typ := p.Type
+		if typ == "" {
+			typ = "object"
+		}
+
+		sum.Fields = append(sum.Fields, Field{Name: name, Type: typ})
+
+		for _, sub := range []json.RawMessage{p.Properties, p.Fields} {
+			if sub == nil {
+				continue
+			}
+
+			if err := sum.fields(name+".", sub); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// data counts the documents and their timestamps
+func (sum *Summary) data(r io.Reader, field string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		sum.Docs++
+
+		var doc struct {
+			Source map[string]json.RawMessage `json:"_source"`
+		}
+
+		if json.Unmarshal(line, &doc) != nil {
+			continue
+		}
+
+		t, ok := Timestamp(doc.Source, field)
+		if !ok {
+			continue
+		}
+
+		t = t.UTC()
+
+		if sum.Timestamps == 0 || t.Before(sum.Min) {
+			sum.Min = t
+		}
+		if sum.Timestamps == 0 || t.After(sum.Max) {
+			sum.Max = t
+		}
+
+		sum.Timestamps++
+		sum.Hours[t.Truncate(time.Hour)]++
+	}
+
+	return scanner.Err()
+}
+
+// Timestamp reads a timestamp field of a document, either a literal key ("a.b") or a path through
+// nested objects. The value is parsed like the rewriter does, strings in one of the known layouts and
+// epoch seconds or milliseconds
+func Timestamp(source map[string]json.RawMessage, field string) (time.Time, bool) {
+	v, ok := source[field]
+
+	if !ok {
+		head, rest, found := strings.Cut(field, ".")
+		if !found {
+			return time.Time{}, false
+		}
+
+		var nested map[string]json.RawMessage
+		if json.Unmarshal(source[head], &nested) != nil {
+			return time.Time{}, false
+		}
+
+		return Timestamp(nested, rest)
+	}
+
+	var value interface{}
+
+	d := json.NewDecoder(bytes.NewReader(v))
+	d.UseNumber()
+
+	if d.Decode(&value) != nil {
+		return time.Time{}, false
+	}
+
+	switch t := value.(type) {
+	case string:
+		ts, _, ok := parseTime(t, "")
+		return ts, ok
+
+	case json.Number:
+		ts, _, ok := parseEpoch(t)
+		return ts, ok
+	}
+
+	return time.Time{}, false
+}
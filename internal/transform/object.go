@@ -0,0 +1,144 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// object is a JSON object that keeps its keys in the order they were read and their values byte for
+// byte, so rewriting a few values leaves the rest of a document as it was
+type object struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// parseObject reads a JSON object, anything else is an error
+func parseObject(b []byte) (*object, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("not a JSON object")
+	}
+
+	o := &object{values: make(map[string]json.RawMessage)}
+
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		// the decoder only returns strings for the keys of an object
+		key, _ := t.(string)
+
+		var v json.RawMessage
+		if err := d.Decode(&v); err != nil {
+			return nil, err
+		}
+
+		o.set(key, v)
+	}
+
+	// the closing brace, then nothing but whitespace
+	if _, err := d.Token(); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after the JSON object")
+	}
+
+	return o, nil
+}
+
+func (o *object) get(key string) (json.RawMessage, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// object returns the value of key when it is an object itself
+func (o *object) object(key string) (*object, bool) {
+	v, ok := o.values[key]
+	if !ok {
+		return nil, false
+	}
+
+	nested, err := parseObject(v)
+	if err != nil {
+		return nil, false
+	}
+
+	return nested, true
+}
+
+// set replaces the value of key in place, a new key is added at the end
+func (o *object) set(key string, v json.RawMessage) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+
+	o.values[key] = v
+}
+
+// rename moves the value of old to key new, keeping its position
+func (o *object) rename(old, new string) {
+	v, ok := o.values[old]
+	if !ok || old == new {
+		return
+	}
+
+	if _, exists := o.values[new]; exists {
+		o.remove(new)
+	}
+
+	for i, k := range o.keys {
+		if k == old {
+			o.keys[i] = new
+		}
+	}
+
+	delete(o.values, old)
+	o.values[new] = v
+}
+
+func (o *object) remove(key string) {
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+
+	delete(o.values, key)
+}
+
+// encode writes the object back out, compact between the keys and with the values as they were read
+func (o *object) encode() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		// a string always marshals
+		key, _ := marshal(k)
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(o.values[k])
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes()
+}
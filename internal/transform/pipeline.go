@@ -0,0 +1,315 @@
+package transform
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+)
+
+// number of data lines handed to each target at a time
+const batchSize = 1000
+
+// Target is a generated archive written by Generate
+type Target struct {
+	Rewriter *Rewriter
+	Writer   io.Writer
+
+	// Progress is called with the number of documents written so far
+	Progress func(docs int)
+
+	// Err is set when generating this target failed
+	Err error
+
+	entries []*entry
+	docs    int
+	lines   chan [][]byte
+	done    chan struct{}
+}
+
+// entry is an archive member, small files are kept in memory and the data file is
+// spooled gzip compressed to disk since the tar header needs the final size
+type entry struct {
+	name  string
+	body  []byte
+	spool *os.File
+	size  int64
+}
+
+// Generate streams the reference archive once (gzip -> tar -> line transform) and writes a
+// transformed archive for every target. Nothing is extracted, the only thing touching disk
+// besides the output archives is a compressed spool of each target's data file in spoolDir
+func Generate(src io.Reader, spoolDir string, targets ...*Target) error {
+	defer func() {
+		for _, t := range targets {
+			for _, e := range t.entries {
+				if e.spool != nil {
+					e.spool.Close()
+					os.Remove(e.spool.Name())
+				}
+			}
+		}
+	}()
+
+	gzr, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+
+	for {
+		header, err := tr.Next()
+
+		switch {
+		case err == io.EOF:
+			finish(targets)
+			return nil
+
+		case err != nil:
+			return err
+
+		case header == nil || header.Typeflag != tar.TypeReg:
+			continue
+		}
+
+		switch Kind(header.Name) {
+		case "data":
+			if err := data(tr, header.Name, spoolDir, targets); err != nil {
+				return err
+			}
+
+		default:
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+
+			for _, t := range targets {
+				t.small(header.Name, b)
+			}
+		}
+	}
+}
+
+// small rewrites a settings or mapping file (anything else is copied as is)
+func (t *Target) small(name string, b []byte) {
+	if t.Err != nil {
+		return
+	}
+
+	var err error
+
+	switch Kind(name) {
+	case "settings":
+		b, err = t.Rewriter.Settings(b)
+		b = append(b, '\n')
+
+	case "mapping":
+		b, err = t.Rewriter.Mapping(b)
+		b = append(b, '\n')
+	}
+
+	if err != nil {
+		t.Err = fmt.Errorf("%s: %v", name, err)
+		return
+	}
+
+	t.entries = append(t.entries, &entry{name: t.Rewriter.EntryName(name), body: b, size: int64(len(b))})
+}
+
+// data fans the lines of the data file out to a goroutine per target
+func data(r io.Reader, name, spoolDir string, targets []*Target) error {
+	for _, t := range targets {
+		if t.Err != nil {
+			continue
+		}
+
+		spool, err := os.CreateTemp(spoolDir, fmt.Sprintf(".%s-*.json.gz", t.Rewriter.NewIndex))
+		if err != nil {
+			t.Err = err
+			continue
+		}
+
+		e := &entry{name: t.Rewriter.EntryName(name), spool: spool}
+		t.entries = append(t.entries, e)
+
+		t.lines = make(chan [][]byte, 4)
+		t.done = make(chan struct{})
+
+		go t.spool(e, name)
+	}
+
+	reader := bufio.NewReader(r)
+	batch := make([][]byte, 0, batchSize)
+
+	send := func() {
+		for _, t := range targets {
+			if t.lines != nil {
+				t.lines <- batch
+			}
+		}
+		batch = make([][]byte, 0, batchSize)
+	}
+
+	var err error
+
+	for err == nil {
+		var line []byte
+
+		line, err = reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			break
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			batch = append(batch, line)
+		}
+
+		if len(batch) == batchSize || (err == io.EOF && len(batch) > 0) {
+			send()
+		}
+	}
+
+	for _, t := range targets {
+		if t.lines != nil {
+			close(t.lines)
+			<-t.done
+			t.lines = nil
+		}
+	}
+
+	if err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// spool rewrites each batch of lines into the compressed spool file, once the target
+// fails the remaining lines are drained so the reader is never blocked
+func (t *Target) spool(e *entry, name string) {
+	defer close(t.done)
+
+	gzw := gzip.NewWriter(e.spool)
+	w := bufio.NewWriter(gzw)
+
+	// the tar entry needs the uncompressed size
+	var size int64
+
+	var n int
+
+	for lines := range t.lines {
+		if t.Err != nil {
+			continue
+		}
+
+		for _, line := range lines {
+			n++
+
+			docs, err := t.Rewriter.Documents(line)
+			if err != nil {
+				t.Err = fmt.Errorf("%s line %d: %v", name, n, err)
+				break
+			}
+
+			for _, doc := range docs {
+				w.Write(doc)
+				w.WriteByte('\n')
+
+				size += int64(len(doc)) + 1
+				t.docs++
+			}
+		}
+
+		if t.Progress != nil && t.Err == nil {
+			t.Progress(t.docs)
+		}
+	}
+
+	if t.Err != nil {
+		return
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Err = err
+		return
+	}
+
+	if err := gzw.Close(); err != nil {
+		t.Err = err
+		return
+	}
+
+	e.size = size
+}
+
+// finish writes the output archive of each target in parallel
+func finish(targets []*Target) {
+	var wg sync.WaitGroup
+
+	for _, t := range targets {
+		if t.Err != nil {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(t *Target) {
+			defer wg.Done()
+			t.Err = t.write()
+		}(t)
+	}
+
+	wg.Wait()
+}
+
+// write tars the entries with the small files first so readers get the settings and
+// mapping before having to go through the data
+func (t *Target) write() error {
+	var entries []helpers.TarEntry
+
+	for _, e := range t.entries {
+		if e.spool == nil {
+			entries = append(entries, helpers.TarEntry{Name: e.name, Size: e.size, Body: bytes.NewReader(e.body)})
+		}
+	}
+
+	for _, e := range t.entries {
+		if e.spool == nil {
+			continue
+		}
+
+		if _, err := e.spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		gzr, err := gzip.NewReader(e.spool)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+
+		entries = append(entries, helpers.TarEntry{Name: e.name, Size: e.size, Body: gzr})
+	}
+
+	return helpers.TarEntries(t.Writer, entries...)
+}
+
+// Docs returns the number of documents written to the target
+func (t *Target) Docs() int {
+	return t.docs
+}
+
+// EntryName renames an archive member from the old index to the new one
+func (r *Rewriter) EntryName(name string) string {
+	return strings.Replace(name, r.OldIndex, r.NewIndex, 1)
+}
@@ -0,0 +1,514 @@
+package transform
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+)
+
+const DefaultTimestampField = "@timestamp"
+
+const (
+	// ShiftDate swaps the date in the timestamp leaving the time of day as is (the date and hour for
+	// hourly indices). Weekly and monthly timestamps can't be swapped and are shifted like ShiftDuration
+	ShiftDate = "date"
+
+	// ShiftDuration parses the timestamp and moves it by the time between the dates (plus jitter)
+	ShiftDuration = "duration"
+)
+
+const (
+	// IDKeep leaves the _id of each document as is
+	IDKeep = "keep"
+
+	// IDRandom gives every document a random UUID
+	IDRandom = "uuid"
+
+	// IDHash derives the _id from the new date and the original _id so reruns produce the same ids,
+	// documents without an _id are left for Elasticsearch to assign one
+	IDHash = "hash"
+)
+
+// Options are the user selectable settings applied to every generated document
+type Options struct {
+	TimestampFields []string
+	Shift           string
+	Jitter          time.Duration
+	Scale           float64
+	IDs             string
+}
+
+// copySpread is how far the timestamps of the extra copies made by Scale are moved when no jitter is set
+const copySpread = 5 * time.Minute
+
+// Rewriter rewrites an elasticdump export of OldIndex (the OldGranularity period starting at OldDate)
+// into NewIndex (the Granularity period starting at NewDate). Only the index name and the configured
+// timestamp fields are touched, the rest of each document is copied as is
+type Rewriter struct {
+	Options
+	OldIndex       string
+	NewIndex       string
+	OldDate        time.Time
+	NewDate        time.Time
+	OldGranularity helpers.Granularity
+	Granularity    helpers.Granularity
+
+	layouts map[string]string
+	random  *rand.Rand
+}
+
+// timestamp formats tried when parsing timestamps, the first one that formats back to the
+// exact same string is used so the generated values keep their precision and offset style
+var layouts = []string{
+	"2006-01-02T15:04:05.000Z07:00",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.000000Z07:00",
+	"2006-01-02T15:04:05.000000000Z07:00",
+	"2006-01-02T15:04:05.000-07:00",
+	"2006-01-02T15:04:05-07:00",
+	"2006-01-02T15:04:05.000Z0700",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.000",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Kind returns the type of elasticdump file (settings, mapping or data) from the file name
+func Kind(name string) string {
+	for _, kind := range []string{"settings", "mapping", "data"} {
+		if strings.HasSuffix(name, fmt.Sprintf("-%s.json", kind)) {
+			return kind
+		}
+	}
+
+	return ""
+}
+
+// Settings renames the index in an elasticdump settings file
+func (r *Rewriter) Settings(b []byte) ([]byte, error) {
+	export, err := parseObject(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings file: %v", err)
+	}
+
+	for _, key := range export.keys {
+		e, ok := export.object(key)
+		if !ok {
+			continue
+		}
+
+		settings, ok := e.object("settings")
+		if !ok {
+			continue
+		}
+
+		index, ok := settings.object("index")
+		if !ok {
+			continue
+		}
+
+		if _, ok := index.get("provided_name"); ok {
+			name, _ := json.Marshal(r.NewIndex)
+
+			index.set("provided_name", name)
+			settings.set("index", index.encode())
+			e.set("settings", settings.encode())
+			export.set(key, e.encode())
+		}
+	}
+
+	return r.rename(export), nil
+}
+
+// Mapping renames the index in an elasticdump mapping file
+func (r *Rewriter) Mapping(b []byte) ([]byte, error) {
+	export, err := parseObject(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mapping file: %v", err)
+	}
+
+	return r.rename(export), nil
+}
+
+// rename moves the export from the old index name key to the new one
+func (r *Rewriter) rename(export *object) []byte {
+	export.rename(r.OldIndex, r.NewIndex)
+
+	return export.encode()
+}
+
+// Documents rewrites a single elasticdump data line into as many documents as Scale calls for.
+// Below 1 the line is kept with a probability of Scale, above 1 extra copies are added with a
+// fresh _id and their timestamps spread around the original
+func (r *Rewriter) Documents(line []byte) ([][]byte, error) {
+	copies := r.copies()
+	if copies == 0 {
+		return nil, nil
+	}
+
+	doc, err := r.Document(line)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := [][]byte{doc}
+
+	for i := 1; i < copies; i++ {
+		spread := r.jitter()
+		if r.Jitter <= 0 {
+			spread = time.Duration(r.rand().Int63n(int64(2*copySpread)+1)) - copySpread
+		}
+
+		doc, err := r.rewrite(line, i, spread, ShiftDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// Document rewrites the _index and timestamp fields of a single elasticdump data line
+func (r *Rewriter) Document(line []byte) ([]byte, error) {
+	return r.rewrite(line, 0, r.jitter(), r.Shift)
+}
+
+// rewrite rewrites a data line moving its timestamps with the given mode and jitter, copy is
+// 0 for the document itself and counts up for the extra copies made by Scale
+func (r *Rewriter) rewrite(line []byte, copy int, jitter time.Duration, shift string) ([]byte, error) {
+	doc, err := parseObject(line)
+	if err != nil {
+		return nil, err
+	}
+
+	var index string
+	if v, ok := doc.get("_index"); ok && json.Unmarshal(v, &index) == nil && index == r.OldIndex {
+		v, _ = json.Marshal(r.NewIndex)
+		doc.set("_index", v)
+	}
+
+	var id string
+	if v, ok := doc.get("_id"); ok {
+		json.Unmarshal(v, &id)
+	}
+
+	if newID := r.id(id, copy); newID != id {
+		v, _ := json.Marshal(newID)
+		doc.set("_id", v)
+	}
+
+	if src, ok := doc.get("_source"); ok && len(r.TimestampFields) > 0 && !bytes.Equal(bytes.TrimSpace(src), []byte("null")) {
+		source, err := parseObject(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid _source: %v", err)
+		}
+
+		// the same jitter is used for every field to keep their order within the document
+		for _, field := range r.TimestampFields {
+			if err := r.rewriteField(source, field, jitter, shift); err != nil {
+				return nil, err
+			}
+		}
+
+		doc.set("_source", source.encode())
+	}
+
+	return doc.encode(), nil
+}
+
+// copies returns how many documents to write for the next line, the fraction of Scale is
+// applied randomly so the total works out on average
+func (r *Rewriter) copies() int {
+	if r.Scale <= 0 || r.Scale == 1 {
+		return 1
+	}
+
+	n := int(r.Scale)
+	if r.rand().Float64() < r.Scale-float64(n) {
+		n++
+	}
+
+	return n
+}
+
+// id returns the _id to use for a document following the IDs strategy, copies always get a
+// new _id since they would otherwise overwrite the document they were copied from
+func (r *Rewriter) id(original string, copy int) string {
+	switch {
+	// hashing nothing but the date would give all of them the same _id
+	case r.IDs == IDHash && original == "":
+		return ""
+
+	case r.IDs == IDHash:
+		h := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%d", r.NewDate.Format(time.RFC3339), original, copy)))
+		return hex.EncodeToString(h[:])
+
+	case r.IDs == IDRandom || copy > 0:
+		return r.newID()
+	}
+
+	return original
+}
+
+// newID returns a random (version 4) UUID, from crypto/rand so generators running in parallel
+// can't produce the same ids
+func (r *Rewriter) newID() string {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		panic(fmt.Sprintf("can't read random bytes: %v", err))
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// rewriteField finds a field by name, either as a literal key ("a.b") or as a path through
+// nested objects, and rewrites its timestamp value in place
+func (r *Rewriter) rewriteField(obj *object, field string, jitter time.Duration, shift string) error {
+	if v, ok := obj.get(field); ok {
+		nv, err := r.timestamp(field, v, jitter, shift)
+		if err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+		obj.set(field, nv)
+		return nil
+	}
+
+	head, rest, found := strings.Cut(field, ".")
+	if !found {
+		return nil
+	}
+
+	nested, ok := obj.object(head)
+	if !ok {
+		return nil
+	}
+
+	if err := r.rewriteField(nested, rest, jitter, shift); err != nil {
+		return err
+	}
+
+	obj.set(head, nested.encode())
+
+	return nil
+}
+
+// timestamp moves a timestamp value to the new date. In date mode date strings get the old date
+// swapped for the new one, otherwise they are parsed and shifted by the time between the dates
+// plus the jitter. Epoch numbers (seconds or milliseconds) are always shifted
+func (r *Rewriter) timestamp(field string, v json.RawMessage, jitter time.Duration, shift string) (json.RawMessage, error) {
+	var value interface{}
+
+	d := json.NewDecoder(bytes.NewReader(v))
+	d.UseNumber()
+
+	if err := d.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	switch t := value.(type) {
+	case string:
+		if shift != ShiftDuration && r.swappable() {
+			prefixes := []string{"2006-01-02"}
+			if r.granularity() == helpers.Hourly {
+				prefixes = []string{"2006-01-02T15", "2006-01-02 15"}
+			}
+
+			for _, layout := range prefixes {
+				oldDate := r.OldDate.Format(layout)
+
+				if strings.HasPrefix(t, oldDate) {
+					return json.Marshal(r.NewDate.Format(layout) + strings.TrimPrefix(t, oldDate))
+				}
+			}
+			return v, nil
+		}
+
+		ts, layout, ok := r.parse(field, t)
+		if !ok {
+			return v, nil
+		}
+
+		return json.Marshal(r.shift(ts, jitter).Format(layout))
+
+	case json.Number:
+		ts, millis, ok := parseEpoch(t)
+		if !ok {
+			return v, nil
+		}
+
+		if millis {
+			return json.Marshal(r.shift(ts, jitter).UnixMilli())
+		}
+		return json.Marshal(r.shift(ts, jitter).Unix())
+	}
+
+	return v, nil
+}
+
+// parse finds the layout of a timestamp string, the layout found for each field is remembered
+func (r *Rewriter) parse(field, value string) (time.Time, string, bool) {
+	if r.layouts == nil {
+		r.layouts = make(map[string]string)
+	}
+
+	t, layout, ok := parseTime(value, r.layouts[field])
+	if ok {
+		r.layouts[field] = layout
+	}
+
+	return t, layout, ok
+}
+
+// parseTime finds the layout of a timestamp string, trying preferred (when set) before the known
+// layouts. Only a layout that formats the time back to the exact same string is taken
+func parseTime(value, preferred string) (time.Time, string, bool) {
+	for _, layout := range append([]string{preferred}, layouts...) {
+		if layout == "" {
+			continue
+		}
+
+		if t, err := time.Parse(layout, value); err == nil && t.Format(layout) == value {
+			return t, layout, true
+		}
+	}
+
+	return time.Time{}, "", false
+}
+
+// parseEpoch reads an epoch timestamp, millis reports whether it is in milliseconds rather than
+// seconds. Anything past the year 5138 in seconds is taken as milliseconds
+func parseEpoch(n json.Number) (t time.Time, millis bool, ok bool) {
+	epoch, err := n.Int64()
+	if err != nil {
+		return time.Time{}, false, false
+	}
+
+	if epoch > 1e11 {
+		return time.UnixMilli(epoch), true, true
+	}
+
+	return time.Unix(epoch, 0), false, true
+}
+
+// swappable reports whether date mode can swap the date (and hour) in the timestamp strings, weekly
+// and monthly periods or a change of granularity always need the timestamps parsed and shifted
+func (r *Rewriter) swappable() bool {
+	g := r.granularity()
+	return r.oldGranularity() == g && (g == helpers.Daily || g == helpers.Hourly)
+}
+
+// granularity of the generated index, daily when not set
+func (r *Rewriter) granularity() helpers.Granularity {
+	if r.Granularity == "" {
+		return helpers.Daily
+	}
+	return r.Granularity
+}
+
+// oldGranularity of the reference index, the generated granularity when not set
+func (r *Rewriter) oldGranularity() helpers.Granularity {
+	if r.OldGranularity == "" {
+		return r.granularity()
+	}
+	return r.OldGranularity
+}
+
+// shift moves a timestamp from the reference period to the new one, the jitter is only applied
+// as far as it keeps the timestamp within the period it is indexed under. When the periods differ
+// in length (months, or a different granularity) timestamps within the reference period are spread
+// proportionally over the new one
+func (r *Rewriter) shift(t time.Time, jitter time.Duration) time.Time {
+	start := r.NewDate
+	end := r.granularity().Next(r.NewDate)
+
+	oldLength := r.oldGranularity().Next(r.OldDate).Sub(r.OldDate)
+	newLength := end.Sub(start)
+	offset := t.Sub(r.OldDate)
+
+	if oldLength != newLength && offset >= 0 && offset < oldLength {
+		t = start.Add(spread(offset, oldLength, newLength)).In(t.Location())
+	} else {
+		t = t.Add(r.NewDate.Sub(r.OldDate))
+	}
+
+	if jitter == 0 || t.Before(start) || !t.Before(end) {
+		return t
+	}
+
+	t = t.Add(jitter)
+
+	switch {
+	case t.Before(start):
+		return start.In(t.Location())
+	case !t.Before(end):
+		return end.Add(-time.Millisecond).In(t.Location())
+	}
+
+	return t
+}
+
+// spread maps an offset within a period of oldLength to the same proportion of newLength. The sum is
+// done on integer nanoseconds (128 bits wide, the product of two months overflows int64) and the
+// result is truncated to the precision of the offset, whole seconds stay whole seconds
+func spread(offset, oldLength, newLength time.Duration) time.Duration {
+	hi, lo := bits.Mul64(uint64(offset), uint64(newLength))
+
+	// offset < oldLength so the quotient is below newLength and hi below oldLength, as Div64 needs
+	q, _ := bits.Div64(hi, lo, uint64(oldLength))
+	d := time.Duration(q)
+
+	for _, unit := range []time.Duration{time.Second, time.Millisecond, time.Microsecond} {
+		if offset%unit == 0 {
+			return d.Truncate(unit)
+		}
+	}
+
+	return d
+}
+
+// jitter returns a random offset between -Jitter and +Jitter for a document
+func (r *Rewriter) jitter() time.Duration {
+	if r.Jitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(r.rand().Int63n(int64(2*r.Jitter)+1)) - r.Jitter
+}
+
+func (r *Rewriter) rand() *rand.Rand {
+	if r.random == nil {
+		r.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return r.random
+}
+
+// marshal is json.Marshal without escaping HTML characters so text in the documents is left byte for byte
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
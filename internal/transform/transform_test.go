@@ -0,0 +1,434 @@
+package transform
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thetherington/IndexCreator/internal/helpers"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// source returns the _source of a rewritten data line
+func source(t *testing.T, line []byte) map[string]json.RawMessage {
+	t.Helper()
+
+	var doc struct {
+		Source map[string]json.RawMessage `json:"_source"`
+	}
+
+	if err := json.Unmarshal(line, &doc); err != nil {
+		t.Fatalf("rewritten line is not JSON: %v\n%s", err, line)
+	}
+
+	return doc.Source
+}
+
+func TestDocument(t *testing.T) {
+	tests := []struct {
+		name   string
+		r      Rewriter
+		line   string
+		expect string
+	}{
+		{
+			name:   "date swap keeps time of day and offset",
+			r:      Rewriter{OldDate: day("2023-03-15"), NewDate: day("2023-04-01")},
+			line:   `{"_index":"log-2023.03.15","_id":"1","_source":{"@timestamp":"2023-03-15T10:11:12.345+02:00"}}`,
+			expect: `{"_index":"log-2023.04.01","_id":"1","_source":{"@timestamp":"2023-04-01T10:11:12.345+02:00"}}`,
+		},
+		{
+			name:   "date swap leaves other dates alone",
+			r:      Rewriter{OldDate: day("2023-03-15"), NewDate: day("2023-04-01")},
+			line:   `{"_index":"log-2023.03.15","_id":"1","_source":{"@timestamp":"2023-03-14T23:59:59Z"}}`,
+			expect: `{"_index":"log-2023.04.01","_id":"1","_source":{"@timestamp":"2023-03-14T23:59:59Z"}}`,
+		},
+		{
+			name: "duration shift keeps the layout",
+			r: Rewriter{
+				Options: Options{Shift: ShiftDuration},
+				OldDate: day("2023-03-15"), NewDate: day("2023-03-17"),
+			},
+			line:   `{"_index":"log-2023.03.15","_id":"1","_source":{"@timestamp":"2023-03-15 10:00:00"}}`,
+			expect: `{"_index":"log-2023.03.17","_id":"1","_source":{"@timestamp":"2023-03-17 10:00:00"}}`,
+		},
+		{
+			name:   "epoch milliseconds",
+			r:      Rewriter{OldDate: day("2023-03-15"), NewDate: day("2023-03-16")},
+			line:   `{"_index":"log-2023.03.15","_id":"1","_source":{"@timestamp":1678874400000}}`,
+			expect: `{"_index":"log-2023.03.16","_id":"1","_source":{"@timestamp":1678960800000}}`,
+		},
+		{
+			name:   "epoch seconds",
+			r:      Rewriter{OldDate: day("2023-03-15"), NewDate: day("2023-03-16")},
+			line:   `{"_index":"log-2023.03.15","_id":"1","_source":{"@timestamp":1678874400}}`,
+			expect: `{"_index":"log-2023.03.16","_id":"1","_source":{"@timestamp":1678960800}}`,
+		},
+		{
+			name: "nested field and key order",
+			r: Rewriter{
+				Options: Options{TimestampFields: []string{"event.created"}},
+				OldDate: day("2023-03-15"), NewDate: day("2023-04-01"),
+			},
+			line:   `{"_score":1,"_index":"log-2023.03.15","_id":"1","_source":{"z":"<&>","event":{"b":[2, 1],"created":"2023-03-15T01:00:00Z"},"a":1}}`,
+			expect: `{"_score":1,"_index":"log-2023.04.01","_id":"1","_source":{"z":"<&>","event":{"b":[2, 1],"created":"2023-04-01T01:00:00Z"},"a":1}}`,
+		},
+		{
+			name: "literal dotted key",
+			r: Rewriter{
+				Options: Options{TimestampFields: []string{"event.created"}},
+				OldDate: day("2023-03-15"), NewDate: day("2023-04-01"),
+			},
+			line:   `{"_index":"log-2023.03.15","_id":"1","_source":{"event.created":"2023-03-15"}}`,
+			expect: `{"_index":"log-2023.04.01","_id":"1","_source":{"event.created":"2023-04-01"}}`,
+		},
+		{
+			name: "hourly swaps the hour",
+			r: Rewriter{
+				OldDate: day("2023-03-15").Add(7 * time.Hour), NewDate: day("2023-03-20").Add(9 * time.Hour),
+				OldGranularity: helpers.Hourly, Granularity: helpers.Hourly,
+			},
+			line:   `{"_index":"log-2023.03.15.07","_id":"1","_source":{"@timestamp":"2023-03-15T07:30:00Z"}}`,
+			expect: `{"_index":"log-2023.03.20.09","_id":"1","_source":{"@timestamp":"2023-03-20T09:30:00Z"}}`,
+		},
+		{
+			name: "daily spread over a month keeps whole seconds",
+			r: Rewriter{
+				OldDate: day("2023-03-15"), NewDate: day("2023-04-01"),
+				OldGranularity: helpers.Daily, Granularity: helpers.Monthly,
+			},
+			line:   `{"_index":"log-2023.03.15","_id":"1","_source":{"@timestamp":"2023-03-15T12:00:00.000Z"}}`,
+			expect: `{"_index":"log-2023.04","_id":"1","_source":{"@timestamp":"2023-04-16T00:00:00.000Z"}}`,
+		},
+		{
+			name:   "no timestamp",
+			r:      Rewriter{OldDate: day("2023-03-15"), NewDate: day("2023-04-01")},
+			line:   `{"_index":"log-2023.03.15","_type":"_doc","_id":"1","_source":{"message":"2023-03-15"}}`,
+			expect: `{"_index":"log-2023.04.01","_type":"_doc","_id":"1","_source":{"message":"2023-03-15"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.r
+
+			if r.TimestampFields == nil {
+				r.TimestampFields = []string{DefaultTimestampField}
+			}
+
+			r.OldIndex = "log-" + r.oldGranularity().Format(r.OldDate)
+			r.NewIndex = "log-" + r.granularity().Format(r.NewDate)
+
+			got, err := r.Document([]byte(tt.line))
+			if err != nil {
+				t.Fatalf("Document() error: %v", err)
+			}
+
+			if string(got) != tt.expect {
+				t.Errorf("Document()\n got  %s\n want %s", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestDocumentErrors(t *testing.T) {
+	r := Rewriter{
+		Options: Options{TimestampFields: []string{DefaultTimestampField}},
+		OldDate: day("2023-03-15"), NewDate: day("2023-04-01"),
+	}
+
+	for _, line := range []string{
+		`not json`,
+		`[1,2]`,
+		`{"_index":"x","_source":"text"}`,
+		`{"_index":"x"} trailing`,
+	} {
+		if _, err := r.Document([]byte(line)); err == nil {
+			t.Errorf("Document(%s) didn't fail", line)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	r := Rewriter{
+		Options: Options{TimestampFields: []string{DefaultTimestampField}, Shift: ShiftDuration, Jitter: time.Hour},
+		OldDate: day("2023-03-15"), NewDate: day("2023-03-16"),
+		random: rand.New(rand.NewSource(1)),
+	}
+
+	tests := []struct {
+		timestamp string
+		min, max  time.Time
+	}{
+		// jitter stays within the hour either way
+		{"2023-03-15T12:00:00Z", day("2023-03-16").Add(11 * time.Hour), day("2023-03-16").Add(13 * time.Hour)},
+
+		// and never moves a timestamp out of the day it is indexed under
+		{"2023-03-15T00:10:00Z", day("2023-03-16"), day("2023-03-16").Add(70 * time.Minute)},
+		{"2023-03-15T23:50:00Z", day("2023-03-16").Add(22*time.Hour + 50*time.Minute), day("2023-03-17").Add(-time.Millisecond)},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 200; i++ {
+			line, err := r.Document([]byte(`{"_index":"x","_source":{"@timestamp":"` + tt.timestamp + `"}}`))
+			if err != nil {
+				t.Fatalf("Document() error: %v", err)
+			}
+
+			var s string
+			json.Unmarshal(source(t, line)["@timestamp"], &s)
+
+			got, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				t.Fatalf("jittered timestamp %q doesn't parse: %v", s, err)
+			}
+
+			if got.Before(tt.min) || got.After(tt.max) {
+				t.Fatalf("%s jittered to %s, outside %s - %s", tt.timestamp, s, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		scale    float64
+		min, max int
+	}{
+		{1, 1000, 1000},
+		{0, 1000, 1000},
+		{3, 3000, 3000},
+		{0.25, 150, 350},
+		{1.5, 1350, 1650},
+	}
+
+	line := []byte(`{"_index":"log-2023.03.15","_id":"a","_source":{"@timestamp":"2023-03-15T12:00:00Z"}}`)
+
+	for _, tt := range tests {
+		r := Rewriter{
+			Options:  Options{TimestampFields: []string{DefaultTimestampField}, Scale: tt.scale, IDs: IDKeep},
+			OldIndex: "log-2023.03.15", NewIndex: "log-2023.03.16",
+			OldDate: day("2023-03-15"), NewDate: day("2023-03-16"),
+			random: rand.New(rand.NewSource(1)),
+		}
+
+		total := 0
+		ids := make(map[string]bool)
+
+		for i := 0; i < 1000; i++ {
+			docs, err := r.Documents(line)
+			if err != nil {
+				t.Fatalf("Documents() error: %v", err)
+			}
+
+			total += len(docs)
+
+			// copies get their own _id, only the document itself keeps the original
+			for j, doc := range docs {
+				var d struct {
+					ID string `json:"_id"`
+				}
+				json.Unmarshal(doc, &d)
+
+				if j == 0 && d.ID != "a" {
+					t.Fatalf("scale %v: the original _id became %q", tt.scale, d.ID)
+				}
+
+				if j > 0 && ids[d.ID] {
+					t.Fatalf("scale %v: copy _id %q is used twice", tt.scale, d.ID)
+				}
+
+				ids[d.ID] = true
+			}
+		}
+
+		if total < tt.min || total > tt.max {
+			t.Errorf("scale %v wrote %d documents for 1000 lines, want %d - %d", tt.scale, total, tt.min, tt.max)
+		}
+	}
+}
+
+func TestIDs(t *testing.T) {
+	r := Rewriter{Options: Options{IDs: IDHash}, NewDate: day("2023-03-16")}
+
+	if r.id("a", 0) != r.id("a", 0) {
+		t.Error("hash ids differ between runs")
+	}
+
+	if r.id("a", 0) == r.id("a", 1) || r.id("a", 0) == r.id("b", 0) {
+		t.Error("hash ids collide")
+	}
+
+	// Elasticsearch assigns the _id of documents without one
+	if id := r.id("", 0); id != "" {
+		t.Errorf("hash id of a document without _id = %q, want none", id)
+	}
+
+	line := `{"_index":"x","_source":{}}`
+	if got, err := r.Document([]byte(line)); err != nil || string(got) != line {
+		t.Errorf("Document(%s) = %s, %v, want it unchanged", line, got, err)
+	}
+
+	r.IDs = IDRandom
+	if id := r.id("a", 0); len(id) != 36 || id[14] != '4' {
+		t.Errorf("random id %q isn't a version 4 UUID", id)
+	}
+
+	// generators started at the same time must not share ids
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := (&Rewriter{Options: Options{IDs: IDRandom}}).id("a", 0)
+		if seen[id] {
+			t.Fatalf("random id %q generated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSpread(t *testing.T) {
+	month := 30 * 24 * time.Hour
+
+	tests := []struct {
+		offset, old, new time.Duration
+		expect           time.Duration
+	}{
+		{0, 24 * time.Hour, month, 0},
+		{12 * time.Hour, 24 * time.Hour, month, 15 * 24 * time.Hour},
+		{time.Hour, 24 * time.Hour, 31 * 24 * time.Hour, 31 * time.Hour},
+
+		// 10s of a 30 day month in a 31 day month is 10.333s, the whole seconds are kept
+		{10 * time.Second, month, 31 * 24 * time.Hour, 10 * time.Second},
+		{1500 * time.Millisecond, month, 31 * 24 * time.Hour, 1550 * time.Millisecond},
+
+		// the product of two months doesn't fit in an int64
+		{month - time.Nanosecond, month, 31 * 24 * time.Hour, 31*24*time.Hour - 2*time.Nanosecond},
+	}
+
+	for _, tt := range tests {
+		if got := spread(tt.offset, tt.old, tt.new); got != tt.expect {
+			t.Errorf("spread(%s, %s, %s) = %s, want %s", tt.offset, tt.old, tt.new, got, tt.expect)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value  string
+		layout string
+		ok     bool
+	}{
+		{"2023-03-15T10:00:00.000Z", "2006-01-02T15:04:05.000Z07:00", true},
+		{"2023-03-15T10:00:00Z", "2006-01-02T15:04:05Z07:00", true},
+		{"2023-03-15T10:00:00+01:00", "2006-01-02T15:04:05Z07:00", true},
+		{"2023-03-15T10:00:00.123456Z", "2006-01-02T15:04:05.000000Z07:00", true},
+		{"2023-03-15T10:00:00.000+0100", "2006-01-02T15:04:05.000Z0700", true},
+		{"2023-03-15T10:00:00", "2006-01-02T15:04:05", true},
+		{"2023-03-15 10:00:00.000", "2006-01-02 15:04:05.000", true},
+		{"2023-03-15", "2006-01-02", true},
+		{"15/03/2023", "", false},
+		{"2023-02-30", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		_, layout, ok := parseTime(tt.value, "")
+		if ok != tt.ok || layout != tt.layout {
+			t.Errorf("parseTime(%q) = %q, %v, want %q, %v", tt.value, layout, ok, tt.layout, tt.ok)
+		}
+	}
+
+	// a preferred layout is tried first
+	if _, layout, _ := parseTime("2023-03-15T10:00:00Z", "2006-01-02T15:04:05Z0700"); layout != "2006-01-02T15:04:05Z0700" {
+		t.Errorf("parseTime() ignored the preferred layout, got %q", layout)
+	}
+}
+
+func TestParseEpoch(t *testing.T) {
+	tests := []struct {
+		value  json.Number
+		expect time.Time
+		millis bool
+		ok     bool
+	}{
+		{"1678874400", time.Unix(1678874400, 0), false, true},
+		{"1678874400000", time.UnixMilli(1678874400000), true, true},
+		{"0", time.Unix(0, 0), false, true},
+		{"1678874400.5", time.Time{}, false, false},
+	}
+
+	for _, tt := range tests {
+		got, millis, ok := parseEpoch(tt.value)
+		if !got.Equal(tt.expect) || millis != tt.millis || ok != tt.ok {
+			t.Errorf("parseEpoch(%s) = %s, %v, %v, want %s, %v, %v", tt.value, got, millis, ok, tt.expect, tt.millis, tt.ok)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	src := map[string]json.RawMessage{
+		"@timestamp":    json.RawMessage(`"2023-03-15T10:00:00Z"`),
+		"epoch":         json.RawMessage(`1678874400000`),
+		"event":         json.RawMessage(`{"created":"2023-03-15 10:00:00"}`),
+		"event.ingest":  json.RawMessage(`"2023-03-15"`),
+		"message":       json.RawMessage(`"hello"`),
+		"notanobject.x": json.RawMessage(`1`),
+	}
+
+	tests := []struct {
+		field  string
+		expect time.Time
+		ok     bool
+	}{
+		{"@timestamp", day("2023-03-15").Add(10 * time.Hour), true},
+		{"epoch", day("2023-03-15").Add(10 * time.Hour), true},
+		{"event.created", day("2023-03-15").Add(10 * time.Hour), true},
+		{"event.ingest", day("2023-03-15"), true},
+		{"message", time.Time{}, false},
+		{"missing", time.Time{}, false},
+		{"message.x", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Timestamp(src, tt.field)
+		if ok != tt.ok || !got.Equal(tt.expect) {
+			t.Errorf("Timestamp(%q) = %s, %v, want %s, %v", tt.field, got, ok, tt.expect, tt.ok)
+		}
+	}
+}
+
+func TestSettingsAndMapping(t *testing.T) {
+	r := Rewriter{OldIndex: "log-2023.03.15", NewIndex: "log-2023.04.01"}
+
+	settings, err := r.Settings([]byte(`{"log-2023.03.15":{"settings":{"index":{"number_of_shards":"1","provided_name":"log-2023.03.15","uuid":"x"}}}}`))
+	if err != nil {
+		t.Fatalf("Settings() error: %v", err)
+	}
+
+	if want := `{"log-2023.04.01":{"settings":{"index":{"number_of_shards":"1","provided_name":"log-2023.04.01","uuid":"x"}}}}`; string(settings) != want {
+		t.Errorf("Settings()\n got  %s\n want %s", settings, want)
+	}
+
+	mapping, err := r.Mapping([]byte(`{"log-2023.03.15":{"mappings":{"properties":{"z":{"type":"keyword"},"a":{"type":"text"}}}}}`))
+	if err != nil {
+		t.Fatalf("Mapping() error: %v", err)
+	}
+
+	if want := `{"log-2023.04.01":{"mappings":{"properties":{"z":{"type":"keyword"},"a":{"type":"text"}}}}}`; string(mapping) != want {
+		t.Errorf("Mapping()\n got  %s\n want %s", mapping, want)
+	}
+
+	if _, err := r.Mapping([]byte(`{"log-2023.03.15":`)); err == nil || !strings.Contains(err.Error(), "invalid mapping file") {
+		t.Errorf("Mapping() of a truncated file returned %v", err)
+	}
+}